@@ -0,0 +1,54 @@
+// Commande generator : écrit un jeu de données KPI synthétique au format
+// attendu par la source CSV du serveur (-csv-dir)
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/generator"
+)
+
+func main() {
+	def := generator.DefaultConfig()
+
+	out := flag.String("out", "data/demo", "répertoire de sortie des CSV")
+	sites := flag.Int("sites", def.Sites, "nombre de sites")
+	maxPDC := flag.Int("pdc", def.MaxPDC, "nombre max de PDC par site (au moins 2)")
+	days := flag.Int("days", def.Days, "nombre de jours générés")
+	rate := flag.Float64("rate", def.SessionsPerPDC, "sessions moyennes par PDC et par jour")
+	vehicles := flag.Int("vehicles", def.Vehicles, "taille de la flotte de véhicules (0 = proportionnelle au trafic)")
+	end := flag.String("end", def.End.AddDate(0, 0, -1).Format("2006-01-02"), "dernier jour généré (AAAA-MM-JJ)")
+	seed := flag.Int64("seed", def.Seed, "graine aléatoire (même graine = même jeu de données)")
+	flag.Parse()
+
+	last, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		log.Fatalf("Invalid -end: %v", err)
+	}
+
+	cfg := generator.Config{
+		Sites:          *sites,
+		MaxPDC:         *maxPDC,
+		Days:           *days,
+		SessionsPerPDC: *rate,
+		Vehicles:       *vehicles,
+		End:            last.AddDate(0, 0, 1),
+		Seed:           *seed,
+	}
+
+	start := time.Now()
+	ds, err := generator.Generate(cfg)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if err := ds.WriteCSV(*out); err != nil {
+		log.Printf("Error writing dataset: %v", err)
+		os.Exit(1)
+	}
+
+	log.Printf("✅ %d sessions, %d alertes, %d défauts, %d charges MAC written to %s in %s",
+		len(ds.Sessions), len(ds.Alertes), len(ds.Defauts), len(ds.ChargesMAC), *out, time.Since(start).Round(time.Millisecond))
+}
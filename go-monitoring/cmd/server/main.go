@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -9,31 +10,63 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/monitoring/charging-stations/internal/config"
 	"github.com/monitoring/charging-stations/internal/database"
+	"github.com/monitoring/charging-stations/internal/generator"
 	"github.com/monitoring/charging-stations/internal/handlers"
 )
 
 func main() {
 	log.Println("🚀 Starting Charging Stations Monitoring Server...")
 
-	// Connexion à la base de données
-	db := database.GetDB()
+	// Chargement de la configuration (fichier, environnement, flags)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Mode démo : jeu de données synthétique écrit dans un répertoire
+	// temporaire et lu comme un export CSV
+	if cfg.Database.Demo {
+		dir, err := writeDemoDataset()
+		if err != nil {
+			log.Fatalf("Error generating demo dataset: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		cfg.Database.CSVDir = dir
+		cfg.Database.DSN = ""
+		cfg.Database.Sources = nil
+		// Ne pas écraser la sauvegarde du cache de production
+		cfg.Cache.SnapshotPath = ""
+	}
+
+	// Contexte racine annulé à la réception de SIGINT/SIGTERM : il stoppe
+	// les refresh en cours et la boucle de refresh périodique
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Connexion aux bases de données (MySQL ou exports CSV hors ligne)
+	db := database.GetDB(ctx, cfg)
 	defer db.Close()
 
 	// Créer le routeur
 	r := mux.NewRouter()
 
 	// Enregistrer les handlers
-	h := handlers.New(db)
+	h, err := handlers.New(db, cfg)
+	if err != nil {
+		log.Fatalf("Error initializing handlers: %v", err)
+	}
 	h.RegisterRoutes(r)
 
 	// Configuration du serveur
 	srv := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Server.Addr,
 		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout.D(),
+		WriteTimeout: cfg.Server.WriteTimeout.D(),
+		IdleTimeout:  cfg.Server.IdleTimeout.D(),
 	}
 
 	// Démarrer le serveur dans une goroutine
@@ -44,22 +77,58 @@ func main() {
 		}
 	}()
 
-	// Rafraîchir le cache périodiquement (toutes les heures)
-	ticker := time.NewTicker(1 * time.Hour)
+	// Rafraîchir le cache périodiquement
 	go func() {
-		for range ticker.C {
-			log.Println("⏰ Scheduled cache refresh...")
-			if err := db.RefreshCache(); err != nil {
-				log.Printf("Error refreshing cache: %v", err)
+		ticker := time.NewTicker(cfg.Cache.RefreshInterval.D())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				log.Println("⏰ Scheduled cache refresh...")
+				if _, err := db.RefreshCache(ctx); err != nil {
+					log.Printf("Error refreshing cache: %v", err)
+				}
 			}
 		}
 	}()
 
 	// Attendre un signal d'interruption
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
+	stop()
 
 	log.Println("🛑 Shutting down server...")
-	ticker.Stop()
+
+	// Laisser les requêtes en cours se terminer, dans la limite du timeout ;
+	// db.Close (différé) attend ensuite la fin du refresh annulé
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.D())
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error during server shutdown: %v", err)
+	}
+
+	log.Println("👋 Server stopped")
+}
+
+// writeDemoDataset génère le jeu de données de démonstration, qui se
+// termine aujourd'hui
+func writeDemoDataset() (string, error) {
+	dir, err := os.MkdirTemp("", "monitoring-demo-")
+	if err != nil {
+		return "", err
+	}
+
+	ds, err := generator.Generate(generator.DefaultConfig())
+	if err == nil {
+		err = ds.WriteCSV(dir)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	log.Printf("🎭 Demo mode: %d synthetic sessions written to %s", len(ds.Sessions), dir)
+	return dir, nil
 }
@@ -3,6 +3,7 @@ module github.com/monitoring/charging-stations
 go 1.21
 
 require (
+	github.com/andybalholm/brotli v1.1.1
 	github.com/go-sql-driver/mysql v1.7.1
 	github.com/gorilla/mux v1.8.1
 )
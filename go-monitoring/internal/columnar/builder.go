@@ -0,0 +1,272 @@
+package columnar
+
+import (
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// Builder accumule des sessions avant de les figer en colonnes
+type Builder struct {
+	s     Sessions
+	index map[string]uint32
+}
+
+// NewBuilder prépare un Builder pour environ n sessions
+func NewBuilder(n int) *Builder {
+	b := &Builder{index: make(map[string]uint32)}
+	b.s.idEnd = make([]uint32, 0, n)
+	b.s.start = make([]int64, 0, n)
+	b.s.end = make([]int64, 0, n)
+	return b
+}
+
+// Len retourne le nombre de sessions accumulées
+func (b *Builder) Len() int {
+	return len(b.s.start)
+}
+
+// Append ajoute une session
+func (b *Builder) Append(s models.Session) {
+	b.s.ids = append(b.s.ids, s.ID...)
+	b.s.idEnd = append(b.s.idEnd, uint32(len(b.s.ids)))
+
+	b.s.start = append(b.s.start, b.nanos(s.DatetimeStart))
+	end := int64(noTime)
+	if s.DatetimeEnd != nil {
+		end = b.nanos(*s.DatetimeEnd)
+	}
+	b.s.end = append(b.s.end, end)
+
+	b.s.site = append(b.s.site, b.intern(s.Site))
+	b.s.pdc = append(b.s.pdc, b.intern(s.PDC))
+	b.s.typeErreur = append(b.s.typeErreur, b.intern(s.TypeErreur))
+	b.s.moment = append(b.s.moment, b.intern(s.Moment))
+	b.s.momentAvancee = append(b.s.momentAvancee, b.intern(s.MomentAvancee))
+	b.s.mac = append(b.s.mac, b.intern(s.MACAddress))
+	b.s.source = append(b.s.source, b.intern(s.Source))
+
+	b.s.stateOfCharge = append(b.s.stateOfCharge, int32(s.StateOfCharge))
+	b.s.charge900V = append(b.s.charge900V, int32(s.Charge900V))
+
+	b.s.eviErrorCode.appendInt(s.EVIErrorCode)
+	b.s.eviMomentStep.appendInt(s.EVIMomentStep)
+	b.s.downstreamCodePC.appendInt(s.DownstreamCodePC)
+	b.s.energyKwh.append(s.EnergyKwh)
+	b.s.meanPowerKw.append(s.MeanPowerKw)
+	b.s.maxPowerKw.append(s.MaxPowerKw)
+	b.s.socStart.append(s.SOCStart)
+	b.s.socEnd.append(s.SOCEnd)
+}
+
+// AppendRow ajoute une session lue dans un autre Sessions, sans la
+// reconstruire
+func (b *Builder) AppendRow(r Row) {
+	src, i := r.s, r.i
+
+	from := uint32(0)
+	if i > 0 {
+		from = src.idEnd[i-1]
+	}
+	b.s.ids = append(b.s.ids, src.ids[from:src.idEnd[i]]...)
+	b.s.idEnd = append(b.s.idEnd, uint32(len(b.s.ids)))
+
+	if b.s.loc == nil && src.start[i] != noTime {
+		b.s.loc = src.loc
+	}
+	b.s.start = append(b.s.start, src.start[i])
+	b.s.end = append(b.s.end, src.end[i])
+
+	b.s.site = append(b.s.site, b.intern(src.dict[src.site[i]]))
+	b.s.pdc = append(b.s.pdc, b.intern(src.dict[src.pdc[i]]))
+	b.s.typeErreur = append(b.s.typeErreur, b.intern(src.dict[src.typeErreur[i]]))
+	b.s.moment = append(b.s.moment, b.intern(src.dict[src.moment[i]]))
+	b.s.momentAvancee = append(b.s.momentAvancee, b.intern(src.dict[src.momentAvancee[i]]))
+	b.s.mac = append(b.s.mac, b.intern(src.dict[src.mac[i]]))
+	b.s.source = append(b.s.source, b.intern(src.dict[src.source[i]]))
+
+	b.s.stateOfCharge = append(b.s.stateOfCharge, src.stateOfCharge[i])
+	b.s.charge900V = append(b.s.charge900V, src.charge900V[i])
+
+	b.s.eviErrorCode.appendFrom(src.eviErrorCode, i)
+	b.s.eviMomentStep.appendFrom(src.eviMomentStep, i)
+	b.s.downstreamCodePC.appendFrom(src.downstreamCodePC, i)
+	b.s.energyKwh.appendFrom(src.energyKwh, i)
+	b.s.meanPowerKw.appendFrom(src.meanPowerKw, i)
+	b.s.maxPowerKw.appendFrom(src.maxPowerKw, i)
+	b.s.socStart.appendFrom(src.socStart, i)
+	b.s.socEnd.appendFrom(src.socEnd, i)
+}
+
+// AppendView ajoute toutes les sessions d'une vue
+func (b *Builder) AppendView(v View) {
+	for it := v.Iter(); it.Next(); {
+		b.AppendRow(it.Row())
+	}
+}
+
+// Build trie les sessions par date de début (tri stable, sessions sans
+// date en tête) et retourne les colonnes ajustées à leur taille. Le
+// Builder ne doit plus servir ensuite.
+func (b *Builder) Build() *Sessions {
+	s := &b.s
+	if s.loc == nil {
+		s.loc = time.UTC
+	}
+
+	n := len(s.start)
+	perm := make([]int32, n)
+	for i := range perm {
+		perm[i] = int32(i)
+	}
+	sort.SliceStable(perm, func(i, j int) bool {
+		return s.start[perm[i]] < s.start[perm[j]]
+	})
+
+	ids := make([]byte, 0, len(s.ids))
+	idEnd := make([]uint32, n)
+	for k, i := range perm {
+		from := uint32(0)
+		if i > 0 {
+			from = s.idEnd[i-1]
+		}
+		ids = append(ids, s.ids[from:s.idEnd[i]]...)
+		idEnd[k] = uint32(len(ids))
+	}
+
+	out := &Sessions{
+		ids:   ids,
+		idEnd: idEnd,
+		start: permute(s.start, perm),
+		end:   permute(s.end, perm),
+		loc:   s.loc,
+
+		dict:          append([]string(nil), s.dict...),
+		site:          permute(s.site, perm),
+		pdc:           permute(s.pdc, perm),
+		typeErreur:    permute(s.typeErreur, perm),
+		moment:        permute(s.moment, perm),
+		momentAvancee: permute(s.momentAvancee, perm),
+		mac:           permute(s.mac, perm),
+		source:        permute(s.source, perm),
+
+		stateOfCharge: permute(s.stateOfCharge, perm),
+		charge900V:    permute(s.charge900V, perm),
+
+		eviErrorCode:     s.eviErrorCode.permute(perm),
+		eviMomentStep:    s.eviMomentStep.permute(perm),
+		downstreamCodePC: s.downstreamCodePC.permute(perm),
+		energyKwh:        s.energyKwh.permute(perm),
+		meanPowerKw:      s.meanPowerKw.permute(perm),
+		maxPowerKw:       s.maxPowerKw.permute(perm),
+		socStart:         s.socStart.permute(perm),
+		socEnd:           s.socEnd.permute(perm),
+	}
+
+	*b = Builder{}
+	return out
+}
+
+// nanos encode une date ; le fuseau de la première date sert à la
+// restitution de toutes les autres
+func (b *Builder) nanos(t time.Time) int64 {
+	if t.IsZero() {
+		return noTime
+	}
+	if b.s.loc == nil {
+		b.s.loc = t.Location()
+	}
+	return t.UnixNano()
+}
+
+func (b *Builder) intern(v string) uint32 {
+	code, ok := b.index[v]
+	if !ok {
+		code = uint32(len(b.s.dict))
+		b.index[v] = code
+		b.s.dict = append(b.s.dict, v)
+	}
+	return code
+}
+
+// permute retourne une copie de values réordonnée selon perm
+func permute[T any](values []T, perm []int32) []T {
+	out := make([]T, len(perm))
+	for k, i := range perm {
+		out[k] = values[i]
+	}
+	return out
+}
+
+// nullable est une colonne de valeurs optionnelles : les valeurs absentes
+// valent zéro et sont marquées dans le masque valid
+type nullable[T int32 | float64] struct {
+	values []T
+	valid  []uint64
+}
+
+func (c *nullable[T]) append(v *T) {
+	i := len(c.values)
+	if i%64 == 0 {
+		c.valid = append(c.valid, 0)
+	}
+	if v == nil {
+		c.values = append(c.values, 0)
+		return
+	}
+	c.values = append(c.values, *v)
+	c.valid[i/64] |= 1 << (i % 64)
+}
+
+func (c *nullable[T]) appendInt(v *int) {
+	if v == nil {
+		c.append(nil)
+		return
+	}
+	n := T(*v)
+	c.append(&n)
+}
+
+func (c *nullable[T]) appendFrom(src nullable[T], i int) {
+	if v, ok := src.get(i); ok {
+		c.append(&v)
+		return
+	}
+	c.append(nil)
+}
+
+func (c nullable[T]) get(i int) (T, bool) {
+	if c.valid[i/64]&(1<<(i%64)) == 0 {
+		return 0, false
+	}
+	return c.values[i], true
+}
+
+func (c nullable[T]) permute(perm []int32) nullable[T] {
+	out := nullable[T]{
+		values: make([]T, len(perm)),
+		valid:  make([]uint64, (len(perm)+63)/64),
+	}
+	for k, i := range perm {
+		if v, ok := c.get(int(i)); ok {
+			out.values[k] = v
+			out.valid[k/64] |= 1 << (k % 64)
+		}
+	}
+	return out
+}
+
+func (c nullable[T]) size() int64 {
+	return sizeOf(c.values) + sizeOf(c.valid)
+}
+
+// count retourne le nombre de valeurs présentes
+func (c nullable[T]) count() int {
+	n := 0
+	for _, w := range c.valid {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
@@ -0,0 +1,141 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"time"
+)
+
+// wire est la forme sérialisée des colonnes, pour sauvegarder le cache
+// sans reconstruire les sessions
+type wire struct {
+	IDs           []byte
+	IDEnd         []uint32
+	Start, End    []int64
+	Location      string
+	Dict          []string
+	Site          []uint32
+	PDC           []uint32
+	TypeErreur    []uint32
+	Moment        []uint32
+	MomentAvancee []uint32
+	MAC           []uint32
+	Source        []uint32
+	StateOfCharge []int32
+	Charge900V    []int32
+	Ints          [3]wireNullable[int32]
+	Floats        [5]wireNullable[float64]
+	// FixedZone signale un fuseau fixe (date lue avec un décalage
+	// explicite) que time.LoadLocation ne sait pas relire ; FixedOffset
+	// est alors son décalage en secondes
+	FixedZone   bool
+	FixedOffset int
+}
+
+type wireNullable[T int32 | float64] struct {
+	Values []T
+	Valid  []uint64
+}
+
+// GobEncode implémente gob.GobEncoder
+func (s *Sessions) GobEncode() ([]byte, error) {
+	w := wire{
+		IDs:           s.ids,
+		IDEnd:         s.idEnd,
+		Start:         s.start,
+		End:           s.end,
+		Location:      s.loc.String(),
+		Dict:          s.dict,
+		Site:          s.site,
+		PDC:           s.pdc,
+		TypeErreur:    s.typeErreur,
+		Moment:        s.moment,
+		MomentAvancee: s.momentAvancee,
+		MAC:           s.mac,
+		Source:        s.source,
+		StateOfCharge: s.stateOfCharge,
+		Charge900V:    s.charge900V,
+	}
+	if !loadable(s.loc) {
+		w.FixedZone = true
+		_, w.FixedOffset = time.Now().In(s.loc).Zone()
+	}
+	for i, c := range s.ints() {
+		w.Ints[i] = wireNullable[int32]{c.values, c.valid}
+	}
+	for i, c := range s.floats() {
+		w.Floats[i] = wireNullable[float64]{c.values, c.valid}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&w); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implémente gob.GobDecoder
+func (s *Sessions) GobDecode(data []byte) error {
+	var w wire
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&w); err != nil {
+		return err
+	}
+
+	// Un fuseau inconnu décalerait toutes les dates : mieux vaut refuser
+	// l'instantané
+	var loc *time.Location
+	if w.FixedZone {
+		loc = time.FixedZone(w.Location, w.FixedOffset)
+	} else {
+		var err error
+		if loc, err = time.LoadLocation(w.Location); err != nil {
+			return fmt.Errorf("unknown session location %q: %w", w.Location, err)
+		}
+	}
+	*s = Sessions{
+		ids:           w.IDs,
+		idEnd:         w.IDEnd,
+		start:         w.Start,
+		end:           w.End,
+		loc:           loc,
+		dict:          w.Dict,
+		site:          w.Site,
+		pdc:           w.PDC,
+		typeErreur:    w.TypeErreur,
+		moment:        w.Moment,
+		momentAvancee: w.MomentAvancee,
+		mac:           w.MAC,
+		source:        w.Source,
+		stateOfCharge: w.StateOfCharge,
+		charge900V:    w.Charge900V,
+	}
+	for i, c := range s.ints() {
+		*c = nullable[int32]{w.Ints[i].Values, w.Ints[i].Valid}
+	}
+	for i, c := range s.floats() {
+		*c = nullable[float64]{w.Floats[i].Values, w.Floats[i].Valid}
+	}
+	return nil
+}
+
+// loadable indique si time.LoadLocation restitue loc d'après son nom ; ce
+// n'est pas le cas d'un fuseau fixe, même nommé
+func loadable(loc *time.Location) bool {
+	loaded, err := time.LoadLocation(loc.String())
+	if err != nil {
+		return false
+	}
+	now := time.Now()
+	_, want := now.In(loc).Zone()
+	_, got := now.In(loaded).Zone()
+	return got == want
+}
+
+func (s *Sessions) ints() []*nullable[int32] {
+	return []*nullable[int32]{&s.eviErrorCode, &s.eviMomentStep, &s.downstreamCodePC}
+}
+
+func (s *Sessions) floats() []*nullable[float64] {
+	return []*nullable[float64]{&s.energyKwh, &s.meanPowerKw, &s.maxPowerKw, &s.socStart, &s.socEnd}
+}
@@ -0,0 +1,130 @@
+package columnar
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+func intp(v int) *int { return &v }
+
+func floatp(v float64) *float64 { return &v }
+
+func roundTrip(t *testing.T, s *Sessions) *Sessions {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var out Sessions
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return &out
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Date(2024, time.July, 1, 10, 45, 0, 0, paris)
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+	}{
+		{name: "UTC", loc: time.UTC},
+		{name: "fuseau nommé", loc: paris},
+		{name: "fuseau fixe", loc: time.FixedZone("", 5*3600+1800)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := []models.Session{
+				{
+					ID:               "a",
+					DatetimeStart:    time.Date(2024, time.July, 1, 10, 0, 0, 0, tt.loc),
+					DatetimeEnd:      &end,
+					Site:             "Site A",
+					PDC:              "PDC1",
+					StateOfCharge:    1,
+					TypeErreur:       "Erreur_EVI",
+					Moment:           "Init",
+					MomentAvancee:    "Init",
+					EVIErrorCode:     intp(12),
+					EVIMomentStep:    intp(3),
+					DownstreamCodePC: intp(0),
+					EnergyKwh:        floatp(12.5),
+					MeanPowerKw:      floatp(40),
+					MaxPowerKw:       floatp(55.25),
+					SOCStart:         floatp(20),
+					SOCEnd:           floatp(80),
+					MACAddress:       "00:11:22:33:44:55",
+					Charge900V:       1,
+					Source:           "prod",
+				},
+				{ID: "b", DatetimeStart: time.Date(2024, time.January, 15, 23, 30, 0, 0, tt.loc), Site: "Site B", PDC: "PDC2"},
+				{ID: "c", Site: "Site A", PDC: "PDC1", EnergyKwh: floatp(0)},
+			}
+
+			want := FromRows(rows)
+			got := roundTrip(t, want)
+
+			if got.Len() != want.Len() {
+				t.Fatalf("Len = %d, want %d", got.Len(), want.Len())
+			}
+			if got.loc.String() != want.loc.String() {
+				t.Errorf("location = %q, want %q", got.loc, want.loc)
+			}
+			for i := 0; i < want.Len(); i++ {
+				checkSession(t, got.Row(i).Session(), want.Row(i).Session())
+			}
+		})
+	}
+}
+
+// checkSession compare deux sessions, dates comprises avec leur décalage
+func checkSession(t *testing.T, got, want models.Session) {
+	t.Helper()
+
+	if !sameTime(got.DatetimeStart, want.DatetimeStart) {
+		t.Errorf("%s: DatetimeStart = %v, want %v", want.ID, got.DatetimeStart, want.DatetimeStart)
+	}
+	if (got.DatetimeEnd == nil) != (want.DatetimeEnd == nil) ||
+		(want.DatetimeEnd != nil && !sameTime(*got.DatetimeEnd, *want.DatetimeEnd)) {
+		t.Errorf("%s: DatetimeEnd = %v, want %v", want.ID, got.DatetimeEnd, want.DatetimeEnd)
+	}
+
+	got.DatetimeStart, want.DatetimeStart = time.Time{}, time.Time{}
+	got.DatetimeEnd, want.DatetimeEnd = nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("session = %+v, want %+v", got, want)
+	}
+}
+
+func sameTime(a, b time.Time) bool {
+	_, offA := a.Zone()
+	_, offB := b.Zone()
+	return a.Equal(b) && offA == offB
+}
+
+func TestGobDecodeUnknownLocation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&wire{Location: "Nowhere/Atlantis"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var s Sessions
+	err := s.GobDecode(buf.Bytes())
+	if err == nil || !strings.Contains(err.Error(), "Nowhere/Atlantis") {
+		t.Fatalf("GobDecode error = %v, want unknown location", err)
+	}
+}
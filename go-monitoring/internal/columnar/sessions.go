@@ -0,0 +1,263 @@
+// Package columnar stocke les sessions du cache en colonnes : une colonne
+// par champ, les chaînes répétées (site, PDC, moment, MAC...) encodées par
+// dictionnaire et les champs optionnels sans pointeur. Row, View et
+// Iterator lisent les colonnes sans reconstruire de models.Session.
+package columnar
+
+import (
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// noTime code une date absente (session sans date de début ou de fin)
+const noTime = math.MinInt64
+
+// Sessions est un ensemble immuable de sessions en colonnes, trié par date
+// de début (sessions sans date en tête). Un Sessions nil est vide.
+type Sessions struct {
+	// ids concatène les identifiants ; idEnd[i] est la fin du i-ème
+	ids   []byte
+	idEnd []uint32
+
+	// start et end sont en nanosecondes Unix (noTime si absente), restituées
+	// dans le fuseau loc
+	start []int64
+	end   []int64
+	loc   *time.Location
+
+	// dict contient les chaînes distinctes de toutes les colonnes texte
+	dict          []string
+	site          []uint32
+	pdc           []uint32
+	typeErreur    []uint32
+	moment        []uint32
+	momentAvancee []uint32
+	mac           []uint32
+	source        []uint32
+
+	stateOfCharge []int32
+	charge900V    []int32
+
+	eviErrorCode     nullable[int32]
+	eviMomentStep    nullable[int32]
+	downstreamCodePC nullable[int32]
+	energyKwh        nullable[float64]
+	meanPowerKw      nullable[float64]
+	maxPowerKw       nullable[float64]
+	socStart         nullable[float64]
+	socEnd           nullable[float64]
+}
+
+// FromRows encode des sessions en colonnes. rows n'est pas modifié.
+func FromRows(rows []models.Session) *Sessions {
+	b := NewBuilder(len(rows))
+	for _, s := range rows {
+		b.Append(s)
+	}
+	return b.Build()
+}
+
+// Len retourne le nombre de sessions
+func (s *Sessions) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.start)
+}
+
+// Row retourne l'accesseur de la i-ème session
+func (s *Sessions) Row(i int) Row {
+	return Row{s: s, i: i}
+}
+
+// All retourne une vue sur toutes les sessions
+func (s *Sessions) All() View {
+	return View{s: s, hi: s.Len()}
+}
+
+// Slice retourne une vue sur les sessions [lo, hi)
+func (s *Sessions) Slice(lo, hi int) View {
+	return View{s: s, lo: lo, hi: hi}
+}
+
+// Select retourne une vue sur les sessions d'indices idx, dans cet ordre.
+// idx appartient ensuite à la vue et ne doit plus être modifié.
+func (s *Sessions) Select(idx []int32) View {
+	if idx == nil {
+		idx = []int32{}
+	}
+	return View{s: s, idx: idx}
+}
+
+// Row donne accès aux champs d'une session sans la reconstruire. Les
+// méthodes portent le nom des champs de models.Session ; les champs
+// optionnels retournent en plus un booléen de présence.
+type Row struct {
+	s *Sessions
+	i int
+}
+
+// Index retourne la position de la session dans son Sessions
+func (r Row) Index() int {
+	return r.i
+}
+
+func (r Row) ID() string {
+	from := uint32(0)
+	if r.i > 0 {
+		from = r.s.idEnd[r.i-1]
+	}
+	return string(r.s.ids[from:r.s.idEnd[r.i]])
+}
+
+func (r Row) DatetimeStart() time.Time {
+	return r.s.time(r.s.start[r.i])
+}
+
+func (r Row) DatetimeEnd() (time.Time, bool) {
+	ns := r.s.end[r.i]
+	return r.s.time(ns), ns != noTime
+}
+
+func (r Row) Site() string          { return r.s.dict[r.s.site[r.i]] }
+func (r Row) PDC() string           { return r.s.dict[r.s.pdc[r.i]] }
+func (r Row) TypeErreur() string    { return r.s.dict[r.s.typeErreur[r.i]] }
+func (r Row) Moment() string        { return r.s.dict[r.s.moment[r.i]] }
+func (r Row) MomentAvancee() string { return r.s.dict[r.s.momentAvancee[r.i]] }
+func (r Row) MACAddress() string    { return r.s.dict[r.s.mac[r.i]] }
+func (r Row) Source() string        { return r.s.dict[r.s.source[r.i]] }
+
+func (r Row) StateOfCharge() int { return int(r.s.stateOfCharge[r.i]) }
+func (r Row) Charge900V() int    { return int(r.s.charge900V[r.i]) }
+
+// OK indique une charge réussie (StateOfCharge à 0)
+func (r Row) OK() bool { return r.s.stateOfCharge[r.i] == 0 }
+
+func (r Row) EVIErrorCode() (int, bool) {
+	v, ok := r.s.eviErrorCode.get(r.i)
+	return int(v), ok
+}
+
+func (r Row) EVIMomentStep() (int, bool) {
+	v, ok := r.s.eviMomentStep.get(r.i)
+	return int(v), ok
+}
+
+func (r Row) DownstreamCodePC() (int, bool) {
+	v, ok := r.s.downstreamCodePC.get(r.i)
+	return int(v), ok
+}
+
+func (r Row) EnergyKwh() (float64, bool)   { return r.s.energyKwh.get(r.i) }
+func (r Row) MeanPowerKw() (float64, bool) { return r.s.meanPowerKw.get(r.i) }
+func (r Row) MaxPowerKw() (float64, bool)  { return r.s.maxPowerKw.get(r.i) }
+func (r Row) SOCStart() (float64, bool)    { return r.s.socStart.get(r.i) }
+func (r Row) SOCEnd() (float64, bool)      { return r.s.socEnd.get(r.i) }
+
+// Session reconstruit la session complète (pour l'affichage et la
+// sauvegarde disque)
+func (r Row) Session() models.Session {
+	s := models.Session{
+		ID:               r.ID(),
+		DatetimeStart:    r.DatetimeStart(),
+		Site:             r.Site(),
+		PDC:              r.PDC(),
+		StateOfCharge:    r.StateOfCharge(),
+		TypeErreur:       r.TypeErreur(),
+		Moment:           r.Moment(),
+		MomentAvancee:    r.MomentAvancee(),
+		EVIErrorCode:     intPtr(r.EVIErrorCode()),
+		EVIMomentStep:    intPtr(r.EVIMomentStep()),
+		DownstreamCodePC: intPtr(r.DownstreamCodePC()),
+		EnergyKwh:        floatPtr(r.EnergyKwh()),
+		MeanPowerKw:      floatPtr(r.MeanPowerKw()),
+		MaxPowerKw:       floatPtr(r.MaxPowerKw()),
+		SOCStart:         floatPtr(r.SOCStart()),
+		SOCEnd:           floatPtr(r.SOCEnd()),
+		MACAddress:       r.MACAddress(),
+		Charge900V:       r.Charge900V(),
+		Source:           r.Source(),
+	}
+	if end, ok := r.DatetimeEnd(); ok {
+		s.DatetimeEnd = &end
+	}
+	return s
+}
+
+func (s *Sessions) time(ns int64) time.Time {
+	if ns == noTime {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).In(s.loc)
+}
+
+func intPtr(v int, ok bool) *int {
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func floatPtr(v float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// Footprint résume l'empreinte mémoire des colonnes
+type Footprint struct {
+	Rows int `json:"rows"`
+	// Strings est le nombre de chaînes distinctes du dictionnaire
+	Strings int `json:"strings"`
+	// Bytes est la taille des colonnes et du dictionnaire
+	Bytes int64 `json:"bytes"`
+	// RowBytes estime la taille des mêmes sessions en []models.Session
+	// (structures, valeurs pointées et chaînes)
+	RowBytes int64 `json:"row_bytes"`
+}
+
+// Footprint estime la mémoire occupée par les colonnes
+func (s *Sessions) Footprint() Footprint {
+	if s == nil {
+		return Footprint{}
+	}
+
+	f := Footprint{Rows: s.Len(), Strings: len(s.dict)}
+	f.Bytes = int64(len(s.ids)) + sizeOf(s.idEnd) + sizeOf(s.start) + sizeOf(s.end) +
+		sizeOf(s.site) + sizeOf(s.pdc) + sizeOf(s.typeErreur) + sizeOf(s.moment) +
+		sizeOf(s.momentAvancee) + sizeOf(s.mac) + sizeOf(s.source) +
+		sizeOf(s.stateOfCharge) + sizeOf(s.charge900V) +
+		s.eviErrorCode.size() + s.eviMomentStep.size() + s.downstreamCodePC.size() +
+		s.energyKwh.size() + s.meanPowerKw.size() + s.maxPowerKw.size() +
+		s.socStart.size() + s.socEnd.size()
+	for _, v := range s.dict {
+		f.Bytes += int64(unsafe.Sizeof(v)) + int64(len(v))
+	}
+
+	// Chaque session en ligne porte sa structure, ses chaînes et une
+	// allocation par champ optionnel renseigné (8 octets, 24 pour une date)
+	f.RowBytes = int64(f.Rows)*int64(unsafe.Sizeof(models.Session{})) + int64(len(s.ids))
+	for _, codes := range [][]uint32{s.site, s.pdc, s.typeErreur, s.moment, s.momentAvancee, s.mac, s.source} {
+		for _, c := range codes {
+			f.RowBytes += int64(len(s.dict[c]))
+		}
+	}
+	for _, ns := range s.end {
+		if ns != noTime {
+			f.RowBytes += int64(unsafe.Sizeof(time.Time{}))
+		}
+	}
+	f.RowBytes += 8 * int64(s.eviErrorCode.count()+s.eviMomentStep.count()+s.downstreamCodePC.count()+
+		s.energyKwh.count()+s.meanPowerKw.count()+s.maxPowerKw.count()+s.socStart.count()+s.socEnd.count())
+
+	return f
+}
+
+func sizeOf[T any](values []T) int64 {
+	var zero T
+	return int64(len(values)) * int64(unsafe.Sizeof(zero))
+}
@@ -0,0 +1,73 @@
+package columnar
+
+import "github.com/monitoring/charging-stations/internal/models"
+
+// View est une sélection immuable de sessions d'un Sessions : une plage
+// [lo, hi) ou, si idx n'est pas nil, une liste d'indices. La vue zéro est
+// vide.
+type View struct {
+	s      *Sessions
+	lo, hi int
+	idx    []int32
+}
+
+// Len retourne le nombre de sessions de la vue
+func (v View) Len() int {
+	if v.idx != nil {
+		return len(v.idx)
+	}
+	return v.hi - v.lo
+}
+
+// Row retourne la k-ième session de la vue
+func (v View) Row(k int) Row {
+	if v.idx != nil {
+		return Row{s: v.s, i: int(v.idx[k])}
+	}
+	return Row{s: v.s, i: v.lo + k}
+}
+
+// Columns retourne les colonnes sous-jacentes
+func (v View) Columns() *Sessions {
+	return v.s
+}
+
+// Iter retourne un itérateur sur les sessions de la vue
+func (v View) Iter() *Iterator {
+	return &Iterator{v: v, k: -1}
+}
+
+// Sessions reconstruit les sessions de la vue (pour les gabarits HTML)
+func (v View) Sessions() []models.Session {
+	out := make([]models.Session, 0, v.Len())
+	for it := v.Iter(); it.Next(); {
+		out = append(out, it.Row().Session())
+	}
+	return out
+}
+
+// Iterator parcourt une vue :
+//
+//	for it := v.Iter(); it.Next(); {
+//		row := it.Row()
+//	}
+type Iterator struct {
+	v View
+	k int
+}
+
+// Next avance sur la session suivante et indique s'il en reste une
+func (it *Iterator) Next() bool {
+	it.k++
+	return it.k < it.v.Len()
+}
+
+// Row retourne la session courante
+func (it *Iterator) Row() Row {
+	return it.v.Row(it.k)
+}
+
+// Pos retourne la position de la session courante dans la vue
+func (it *Iterator) Pos() int {
+	return it.k
+}
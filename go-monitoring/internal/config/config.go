@@ -0,0 +1,531 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// EnvPrefix préfixe toutes les variables d'environnement reconnues
+const EnvPrefix = "MONITORING_"
+
+// Config regroupe toute la configuration du serveur
+type Config struct {
+	Server   ServerConfig   `json:"server"`
+	Database DatabaseConfig `json:"database"`
+	Cache    CacheConfig    `json:"cache"`
+	Web      WebConfig      `json:"web"`
+}
+
+// ServerConfig configure le serveur HTTP
+type ServerConfig struct {
+	Addr         string   `json:"addr"`
+	ReadTimeout  Duration `json:"read_timeout"`
+	WriteTimeout Duration `json:"write_timeout"`
+	IdleTimeout  Duration `json:"idle_timeout"`
+	// ShutdownTimeout borne l'attente des requêtes en cours à l'arrêt
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+}
+
+// DatabaseConfig configure la source des données KPI : MySQL par défaut,
+// ou un répertoire d'exports CSV si CSVDir est renseigné. Sources permet de
+// fédérer plusieurs bases nommées ; les réglages de pool, de timeout et de
+// reconnexion s'appliquent alors à chacune.
+type DatabaseConfig struct {
+	// Demo remplace les bases par un jeu de données synthétique généré au
+	// démarrage
+	Demo            bool           `json:"demo"`
+	CSVDir          string         `json:"csv_dir"`
+	DSN             string         `json:"dsn"`
+	DSNFile         string         `json:"dsn_file"`
+	Sources         []SourceConfig `json:"sources"`
+	MaxOpenConns    int            `json:"max_open_conns"`
+	MaxIdleConns    int            `json:"max_idle_conns"`
+	ConnMaxLifetime Duration       `json:"conn_max_lifetime"`
+	// LoadConcurrency borne le nombre de tables chargées en parallèle à
+	// chaque refresh (au plus MaxOpenConns)
+	LoadConcurrency int `json:"load_concurrency"`
+	// QueryTimeout borne chaque requête de chargement (0 = sans limite)
+	QueryTimeout Duration `json:"query_timeout"`
+	// ConnectRetryMin et ConnectRetryMax bornent l'attente, doublée à
+	// chaque échec, entre deux tentatives de connexion
+	ConnectRetryMin Duration `json:"connect_retry_min"`
+	ConnectRetryMax Duration `json:"connect_retry_max"`
+}
+
+// SourceConfig décrit une base KPI fédérée (MySQL ou exports CSV). Son nom
+// étiquette les lignes chargées et sert de dimension de filtre.
+type SourceConfig struct {
+	Name    string `json:"name"`
+	CSVDir  string `json:"csv_dir"`
+	DSN     string `json:"dsn"`
+	DSNFile string `json:"dsn_file"`
+}
+
+// SourceList retourne les sources à charger : les sources nommées si
+// elles sont configurées, sinon une source unique sans nom construite à
+// partir de CSVDir/DSN
+func (c DatabaseConfig) SourceList() []SourceConfig {
+	if len(c.Sources) > 0 {
+		return c.Sources
+	}
+	return []SourceConfig{{CSVDir: c.CSVDir, DSN: c.DSN, DSNFile: c.DSNFile}}
+}
+
+// CacheConfig configure le cache KPI. Entre deux rechargements complets,
+// seules les sessions démarrées après le filigrane (dernière session connue
+// moins IncrementalLookback) sont relues ; FullRefreshInterval à 0 désactive
+// le mode incrémental.
+type CacheConfig struct {
+	RefreshInterval     Duration `json:"refresh_interval"`
+	FullRefreshInterval Duration `json:"full_refresh_interval"`
+	IncrementalLookback Duration `json:"incremental_lookback"`
+	// SnapshotPath est le fichier où le dernier instantané valide est
+	// sauvegardé puis relu au démarrage ("" = désactivé)
+	SnapshotPath string `json:"snapshot_path"`
+	// MemoEntries borne le nombre de résultats filtrés mémorisés entre
+	// deux refresh (0 = désactivé)
+	MemoEntries int `json:"memo_entries"`
+}
+
+// WebConfig configure les chemins des assets
+type WebConfig struct {
+	TemplatesDir string `json:"templates_dir"`
+	StaticDir    string `json:"static_dir"`
+}
+
+// Duration est une time.Duration lisible en JSON ("15s", "1h")
+type Duration time.Duration
+
+// D retourne la valeur en time.Duration
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+// String implémente fmt.Stringer
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON encode la durée sous forme de chaîne
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepte une chaîne ("15s") ou un nombre de secondes
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(v)
+		return nil
+	}
+
+	var secs float64
+	if err := json.Unmarshal(b, &secs); err != nil {
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	*d = Duration(secs * float64(time.Second))
+	return nil
+}
+
+// Default retourne la configuration par défaut
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:         ":8080",
+			ReadTimeout:  Duration(15 * time.Second),
+			WriteTimeout: Duration(15 * time.Second),
+			IdleTimeout:  Duration(60 * time.Second),
+
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(5 * time.Minute),
+			LoadConcurrency: 4,
+			QueryTimeout:    Duration(2 * time.Minute),
+			ConnectRetryMin: Duration(2 * time.Second),
+			ConnectRetryMax: Duration(2 * time.Minute),
+		},
+		Cache: CacheConfig{
+			RefreshInterval:     Duration(1 * time.Hour),
+			FullRefreshInterval: Duration(24 * time.Hour),
+			IncrementalLookback: Duration(6 * time.Hour),
+			SnapshotPath:        "data/cache-snapshot.gob.gz",
+			MemoEntries:         256,
+		},
+		Web: WebConfig{
+			TemplatesDir: "web/templates",
+			StaticDir:    "web/static",
+		},
+	}
+}
+
+// Load construit la configuration : défauts, puis fichier JSON, puis
+// variables d'environnement, puis flags de la ligne de commande
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("monitoring", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "chemin du fichier de configuration JSON")
+	flags := bindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(&cfg, *configPath); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	flags.apply(fs, &cfg)
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile lit un fichier JSON par-dessus la configuration courante
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	// Les chemins relatifs du fichier sont relatifs au fichier lui-même ;
+	// seuls les chemins renseignés par le fichier sont concernés, pas les
+	// valeurs par défaut
+	var set Config
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	type pathField struct{ set, dst *string }
+	paths := []pathField{
+		{&set.Database.DSNFile, &cfg.Database.DSNFile},
+		{&set.Database.CSVDir, &cfg.Database.CSVDir},
+		{&set.Cache.SnapshotPath, &cfg.Cache.SnapshotPath},
+		{&set.Web.TemplatesDir, &cfg.Web.TemplatesDir},
+		{&set.Web.StaticDir, &cfg.Web.StaticDir},
+	}
+	for i := range cfg.Database.Sources {
+		src := &cfg.Database.Sources[i]
+		paths = append(paths,
+			pathField{&src.DSNFile, &src.DSNFile},
+			pathField{&src.CSVDir, &src.CSVDir})
+	}
+	for _, p := range paths {
+		if *p.set != "" && !filepath.IsAbs(*p.dst) {
+			*p.dst = filepath.Join(filepath.Dir(path), *p.dst)
+		}
+	}
+
+	return nil
+}
+
+// envBinding associe une variable d'environnement à un champ
+type envBinding struct {
+	name string
+	set  func(*Config, string) error
+}
+
+func envBindings() []envBinding {
+	return []envBinding{
+		{"ADDR", func(c *Config, v string) error { c.Server.Addr = v; return nil }},
+		{"READ_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.ReadTimeout })},
+		{"WRITE_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.WriteTimeout })},
+		{"IDLE_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.IdleTimeout })},
+		{"SHUTDOWN_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Server.ShutdownTimeout })},
+		{"DEMO", boolSetter(func(c *Config) *bool { return &c.Database.Demo })},
+		{"CSV_DIR", func(c *Config, v string) error { c.Database.CSVDir = v; return nil }},
+		{"DB_DSN", func(c *Config, v string) error { c.Database.DSN = v; return nil }},
+		{"DB_DSN_FILE", func(c *Config, v string) error { c.Database.DSNFile = v; return nil }},
+		{"DB_MAX_OPEN_CONNS", intSetter(func(c *Config) *int { return &c.Database.MaxOpenConns })},
+		{"DB_MAX_IDLE_CONNS", intSetter(func(c *Config) *int { return &c.Database.MaxIdleConns })},
+		{"DB_LOAD_CONCURRENCY", intSetter(func(c *Config) *int { return &c.Database.LoadConcurrency })},
+		{"DB_CONN_MAX_LIFETIME", durationSetter(func(c *Config) *Duration { return &c.Database.ConnMaxLifetime })},
+		{"DB_QUERY_TIMEOUT", durationSetter(func(c *Config) *Duration { return &c.Database.QueryTimeout })},
+		{"DB_CONNECT_RETRY_MIN", durationSetter(func(c *Config) *Duration { return &c.Database.ConnectRetryMin })},
+		{"DB_CONNECT_RETRY_MAX", durationSetter(func(c *Config) *Duration { return &c.Database.ConnectRetryMax })},
+		{"CACHE_REFRESH_INTERVAL", durationSetter(func(c *Config) *Duration { return &c.Cache.RefreshInterval })},
+		{"CACHE_FULL_REFRESH_INTERVAL", durationSetter(func(c *Config) *Duration { return &c.Cache.FullRefreshInterval })},
+		{"CACHE_INCREMENTAL_LOOKBACK", durationSetter(func(c *Config) *Duration { return &c.Cache.IncrementalLookback })},
+		{"CACHE_SNAPSHOT_PATH", func(c *Config, v string) error { c.Cache.SnapshotPath = v; return nil }},
+		{"CACHE_MEMO_ENTRIES", intSetter(func(c *Config) *int { return &c.Cache.MemoEntries })},
+		{"TEMPLATES_DIR", func(c *Config, v string) error { c.Web.TemplatesDir = v; return nil }},
+		{"STATIC_DIR", func(c *Config, v string) error { c.Web.StaticDir = v; return nil }},
+	}
+}
+
+func applyEnv(cfg *Config) error {
+	for _, b := range envBindings() {
+		v, ok := os.LookupEnv(EnvPrefix + b.name)
+		if !ok {
+			continue
+		}
+		if err := b.set(cfg, v); err != nil {
+			return fmt.Errorf("invalid %s%s: %w", EnvPrefix, b.name, err)
+		}
+	}
+	return nil
+}
+
+func durationSetter(field func(*Config) *Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = Duration(d)
+		return nil
+	}
+}
+
+func boolSetter(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func intSetter(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// flagValues contient les valeurs brutes des flags avant application
+type flagValues struct {
+	addr            *string
+	demo            *bool
+	csvDir          *string
+	dsnFile         *string
+	maxOpenConns    *int
+	maxIdleConns    *int
+	loadConcurrency *int
+	refreshInterval *time.Duration
+	fullRefresh     *time.Duration
+	snapshotPath    *string
+	templatesDir    *string
+	staticDir       *string
+}
+
+func bindFlags(fs *flag.FlagSet) *flagValues {
+	return &flagValues{
+		addr:            fs.String("addr", "", "adresse d'écoute HTTP (ex: :8080)"),
+		demo:            fs.Bool("demo", false, "démarre sur un jeu de données synthétique (sans base)"),
+		csvDir:          fs.String("csv-dir", "", "répertoire d'exports CSV kpi_* (remplace MySQL)"),
+		dsnFile:         fs.String("dsn-file", "", "fichier contenant le DSN MySQL"),
+		maxOpenConns:    fs.Int("db-max-open-conns", 0, "nombre max de connexions MySQL ouvertes"),
+		maxIdleConns:    fs.Int("db-max-idle-conns", 0, "nombre max de connexions MySQL inactives"),
+		loadConcurrency: fs.Int("db-load-concurrency", 0, "nombre max de tables chargées en parallèle"),
+		refreshInterval: fs.Duration("refresh-interval", 0, "intervalle de rafraîchissement du cache"),
+		fullRefresh:     fs.Duration("full-refresh-interval", 0, "intervalle entre deux rechargements complets des sessions (0 = toujours complet)"),
+		snapshotPath:    fs.String("snapshot-path", "", "fichier de sauvegarde du cache (vide = désactivé)"),
+		templatesDir:    fs.String("templates-dir", "", "répertoire des templates HTML"),
+		staticDir:       fs.String("static-dir", "", "répertoire des fichiers statiques"),
+	}
+}
+
+// apply n'applique que les flags explicitement passés
+func (f *flagValues) apply(fs *flag.FlagSet, cfg *Config) {
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "addr":
+			cfg.Server.Addr = *f.addr
+		case "demo":
+			cfg.Database.Demo = *f.demo
+		case "csv-dir":
+			cfg.Database.CSVDir = *f.csvDir
+		case "dsn-file":
+			cfg.Database.DSNFile = *f.dsnFile
+		case "db-max-open-conns":
+			cfg.Database.MaxOpenConns = *f.maxOpenConns
+		case "db-max-idle-conns":
+			cfg.Database.MaxIdleConns = *f.maxIdleConns
+		case "db-load-concurrency":
+			cfg.Database.LoadConcurrency = *f.loadConcurrency
+		case "refresh-interval":
+			cfg.Cache.RefreshInterval = Duration(*f.refreshInterval)
+		case "full-refresh-interval":
+			cfg.Cache.FullRefreshInterval = Duration(*f.fullRefresh)
+		case "snapshot-path":
+			cfg.Cache.SnapshotPath = *f.snapshotPath
+		case "templates-dir":
+			cfg.Web.TemplatesDir = *f.templatesDir
+		case "static-dir":
+			cfg.Web.StaticDir = *f.staticDir
+		}
+	})
+}
+
+// resolveSecrets lit les DSN depuis les fichiers secrets si besoin
+func (c *Config) resolveSecrets() error {
+	// Le mode démo n'ouvre aucune base
+	if c.Database.Demo {
+		return nil
+	}
+	if err := readDSNFile(&c.Database.DSN, c.Database.DSNFile); err != nil {
+		return err
+	}
+	for i := range c.Database.Sources {
+		src := &c.Database.Sources[i]
+		if err := readDSNFile(&src.DSN, src.DSNFile); err != nil {
+			return fmt.Errorf("source %q: %w", src.Name, err)
+		}
+	}
+	return nil
+}
+
+func readDSNFile(dsn *string, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading database dsn file: %w", err)
+	}
+	*dsn = strings.TrimSpace(string(data))
+	return nil
+}
+
+// Validate vérifie la cohérence de la configuration
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must not be negative"))
+	}
+
+	if len(c.Database.Sources) == 0 && !c.Database.Demo {
+		if c.Database.CSVDir != "" {
+			if err := checkDir(c.Database.CSVDir); err != nil {
+				errs = append(errs, fmt.Errorf("database.csv_dir: %w", err))
+			}
+		} else if c.Database.DSN == "" {
+			errs = append(errs, fmt.Errorf("database.dsn is required (set %sDB_DSN, %sDB_DSN_FILE or %sCSV_DIR)", EnvPrefix, EnvPrefix, EnvPrefix))
+		} else if _, err := mysql.ParseDSN(c.Database.DSN); err != nil {
+			errs = append(errs, fmt.Errorf("database.dsn: %w", err))
+		}
+	}
+	names := make(map[string]bool, len(c.Database.Sources))
+	for i, src := range c.Database.Sources {
+		switch {
+		case src.Name == "":
+			errs = append(errs, fmt.Errorf("database.sources[%d].name is required", i))
+		case names[src.Name]:
+			errs = append(errs, fmt.Errorf("database.sources[%d]: duplicate name %q", i, src.Name))
+		}
+		names[src.Name] = true
+
+		if src.CSVDir != "" {
+			if err := checkDir(src.CSVDir); err != nil {
+				errs = append(errs, fmt.Errorf("database.sources[%d].csv_dir: %w", i, err))
+			}
+		} else if src.DSN == "" {
+			errs = append(errs, fmt.Errorf("database.sources[%d] (%s): dsn, dsn_file or csv_dir is required", i, src.Name))
+		} else if _, err := mysql.ParseDSN(src.DSN); err != nil {
+			errs = append(errs, fmt.Errorf("database.sources[%d] (%s): dsn: %w", i, src.Name, err))
+		}
+	}
+	if c.Database.MaxOpenConns <= 0 {
+		errs = append(errs, errors.New("database.max_open_conns must be positive"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns must be between 0 and max_open_conns"))
+	}
+	if c.Database.LoadConcurrency <= 0 || c.Database.LoadConcurrency > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.load_concurrency must be between 1 and max_open_conns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database.conn_max_lifetime must not be negative"))
+	}
+	if c.Database.QueryTimeout < 0 {
+		errs = append(errs, errors.New("database.query_timeout must not be negative"))
+	}
+	if c.Database.ConnectRetryMin <= 0 || c.Database.ConnectRetryMax < c.Database.ConnectRetryMin {
+		errs = append(errs, errors.New("database.connect_retry_min must be positive and not exceed connect_retry_max"))
+	}
+
+	if c.Cache.RefreshInterval < Duration(time.Minute) {
+		errs = append(errs, errors.New("cache.refresh_interval must be at least 1m"))
+	}
+	if c.Cache.FullRefreshInterval != 0 && c.Cache.FullRefreshInterval < c.Cache.RefreshInterval {
+		errs = append(errs, errors.New("cache.full_refresh_interval must be 0 or at least cache.refresh_interval"))
+	}
+	if c.Cache.IncrementalLookback < 0 {
+		errs = append(errs, errors.New("cache.incremental_lookback must not be negative"))
+	}
+	if c.Cache.MemoEntries < 0 {
+		errs = append(errs, errors.New("cache.memo_entries must not be negative"))
+	}
+
+	if err := checkDir(c.Web.TemplatesDir); err != nil {
+		errs = append(errs, fmt.Errorf("web.templates_dir: %w", err))
+	}
+	if err := checkDir(c.Web.StaticDir); err != nil {
+		errs = append(errs, fmt.Errorf("web.static_dir: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func checkDir(path string) error {
+	if path == "" {
+		return errors.New("is required")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig crée dans un répertoire temporaire le fichier de
+// configuration et les répertoires web qu'il référence
+func writeConfig(t *testing.T, content string) (dir, path string) {
+	t.Helper()
+
+	dir = t.TempDir()
+	for _, sub := range []string{"tpl", "static", "exports"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path = filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	_, path := writeConfig(t, `{
+		"server": {"addr": ":1000"},
+		"database": {"dsn": "user:pass@tcp(db:3306)/Charges", "max_open_conns": 10, "max_idle_conns": 2},
+		"cache": {"memo_entries": 5},
+		"web": {"templates_dir": "tpl", "static_dir": "static"}
+	}`)
+	t.Setenv(EnvPrefix+"ADDR", ":2000")
+	t.Setenv(EnvPrefix+"DB_MAX_OPEN_CONNS", "20")
+
+	cfg, err := Load([]string{"-config", path, "-addr", ":3000"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"flag over env and file", cfg.Server.Addr, ":3000"},
+		{"env over file", cfg.Database.MaxOpenConns, 20},
+		{"file over default", cfg.Database.MaxIdleConns, 2},
+		{"file over default", cfg.Cache.MemoEntries, 5},
+		{"default", cfg.Database.QueryTimeout, Duration(2 * time.Minute)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadFileRelativePaths(t *testing.T) {
+	dir, path := writeConfig(t, `{
+		"database": {"dsn_file": "secret.dsn", "csv_dir": "exports"},
+		"cache": {"snapshot_path": "data/cache.gob.gz"},
+		"web": {"templates_dir": "tpl", "static_dir": "/abs/static"}
+	}`)
+	if err := os.WriteFile(filepath.Join(dir, "secret.dsn"), []byte("user:pass@tcp(db:3306)/Charges\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Default()
+	if err := loadFile(&cfg, path); err != nil {
+		t.Fatalf("loadFile: %v", err)
+	}
+	if err := cfg.resolveSecrets(); err != nil {
+		t.Fatalf("resolveSecrets: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"dsn_file", cfg.Database.DSNFile, filepath.Join(dir, "secret.dsn")},
+		{"dsn", cfg.Database.DSN, "user:pass@tcp(db:3306)/Charges"},
+		{"csv_dir", cfg.Database.CSVDir, filepath.Join(dir, "exports")},
+		{"snapshot_path", cfg.Cache.SnapshotPath, filepath.Join(dir, "data/cache.gob.gz")},
+		{"templates_dir", cfg.Web.TemplatesDir, filepath.Join(dir, "tpl")},
+		// Absolu : inchangé
+		{"static_dir", cfg.Web.StaticDir, "/abs/static"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Les défauts absents du fichier restent relatifs au répertoire courant
+	cfg = Default()
+	_, path = writeConfig(t, `{"server": {"addr": ":9000"}}`)
+	if err := loadFile(&cfg, path); err != nil {
+		t.Fatalf("loadFile: %v", err)
+	}
+	if want := Default().Web; cfg.Web != want {
+		t.Errorf("web = %+v, want defaults %+v", cfg.Web, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir, _ := writeConfig(t, `{}`)
+	valid := func() Config {
+		cfg := Default()
+		cfg.Database.DSN = "user:pass@tcp(db:3306)/Charges"
+		cfg.Web.TemplatesDir = filepath.Join(dir, "tpl")
+		cfg.Web.StaticDir = filepath.Join(dir, "static")
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(*Config) {},
+		},
+		{
+			name: "all errors reported",
+			mutate: func(c *Config) {
+				c.Database.DSN = ""
+				c.Database.QueryTimeout = Duration(-time.Second)
+				c.Cache.RefreshInterval = Duration(10 * time.Second)
+				c.Web.TemplatesDir = filepath.Join(dir, "missing")
+			},
+			want: []string{
+				"database.dsn is required",
+				"database.query_timeout must not be negative",
+				"cache.refresh_interval must be at least 1m",
+				"web.templates_dir:",
+			},
+		},
+		{
+			name:   "malformed dsn",
+			mutate: func(c *Config) { c.Database.DSN = "not a dsn" },
+			want:   []string{"database.dsn:"},
+		},
+		{
+			name: "sources",
+			mutate: func(c *Config) {
+				c.Database.Sources = []SourceConfig{
+					{Name: "prod", DSN: "user:pass@tcp(db:3306)/Charges"},
+					{Name: "prod", CSVDir: filepath.Join(dir, "exports")},
+					{},
+				}
+			},
+			want: []string{
+				`database.sources[1]: duplicate name "prod"`,
+				"database.sources[2].name is required",
+				"database.sources[2] (): dsn, dsn_file or csv_dir is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate = nil, want %q", tt.want)
+			}
+			// errors.Join : une erreur par ligne
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.want) {
+				t.Errorf("Validate returned %d errors, want %d:\n%v", len(lines), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate = %v, missing %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// Noms des tables KPI, utilisés aussi comme noms de fichiers CSV
+const (
+	TableSessions           = "kpi_sessions"
+	TableAlertes            = "kpi_alertes"
+	TableDefauts            = "kpi_defauts_log"
+	TableSuspicious         = "kpi_suspicious_under_1kwh"
+	TableMultiAttempts      = "kpi_multi_attempts_hour"
+	TableChargesMAC         = "kpi_charges_mac"
+	TableEvo                = "kpi_evo"
+	TableChargesDaily       = "kpi_charges_daily_by_site"
+	TableDurationsSiteDaily = "kpi_durations_site_daily"
+	TableDurationsPDCDaily  = "kpi_durations_pdc_daily"
+)
+
+// CSVSource lit un répertoire d'exports CSV nommés d'après les tables
+// (kpi_sessions.csv, kpi_alertes.csv, ...). Les colonnes portent les mêmes
+// noms que dans MySQL ; le séparateur (',' ou ';') est détecté.
+type CSVSource struct {
+	dir string
+}
+
+// NewCSVSource crée une source lisant les CSV du répertoire dir
+func NewCSVSource(dir string) *CSVSource {
+	return &CSVSource{dir: dir}
+}
+
+// Name implémente DataSource
+func (c *CSVSource) Name() string {
+	return "csv:" + c.dir
+}
+
+// LoadSessions implémente DataSource
+func (c *CSVSource) LoadSessions(ctx context.Context, rej *Rejects) ([]models.Session, error) {
+	var sessions []models.Session
+	err := c.readTable(ctx, TableSessions, rej, []string{
+		"ID", "Datetime start", "Datetime end", "PDC", "State of charge(0:good, 1:error)",
+		"type_erreur", "moment", "moment_avancee", "EVI Error Code", "EVI Status during error",
+		"Downstream Code PC", "Energy (Kwh)", "Mean Power (Kw)", "Max Power (Kw)",
+		"SOC Start", "SOC End", "MAC Address", "charge_900V",
+	}, func(r *csvRow) error {
+		var s models.Session
+		s.ID = r.str("ID")
+		s.DatetimeStart = r.time("Datetime start")
+		s.DatetimeEnd = r.timePtr("Datetime end")
+		s.Site = r.coalesce("Site", "Name Project")
+		s.PDC = r.str("PDC")
+		s.StateOfCharge = r.int("State of charge(0:good, 1:error)")
+		s.TypeErreur = r.str("type_erreur")
+		s.Moment = r.str("moment")
+		s.MomentAvancee = r.str("moment_avancee")
+		s.EVIErrorCode = r.intPtr("EVI Error Code")
+		s.EVIMomentStep = r.intPtr("EVI Status during error")
+		s.DownstreamCodePC = r.intPtr("Downstream Code PC")
+		s.EnergyKwh = r.floatPtr("Energy (Kwh)")
+		s.MeanPowerKw = r.floatPtr("Mean Power (Kw)")
+		s.MaxPowerKw = r.floatPtr("Max Power (Kw)")
+		s.SOCStart = r.floatPtr("SOC Start")
+		s.SOCEnd = r.floatPtr("SOC End")
+		s.MACAddress = r.str("MAC Address")
+		s.Charge900V = r.int("charge_900V")
+		if r.err != nil {
+			return r.err
+		}
+		sessions = append(sessions, s)
+		return nil
+	})
+	return sessions, err
+}
+
+// LoadAlertes implémente DataSource
+func (c *CSVSource) LoadAlertes(ctx context.Context, rej *Rejects) ([]models.Alerte, error) {
+	var alertes []models.Alerte
+	err := c.readTable(ctx, TableAlertes, rej, []string{
+		"Site", "PDC", "type_erreur", "detection", "occurrences_12h", "moment", "evi_code", "downstream_code_pc",
+	}, func(r *csvRow) error {
+		a := models.Alerte{
+			Site:             r.str("Site"),
+			PDC:              r.str("PDC"),
+			TypeErreur:       r.str("type_erreur"),
+			Detection:        r.time("detection"),
+			Occurrences12h:   r.int("occurrences_12h"),
+			Moment:           r.str("moment"),
+			EVICode:          r.intPtr("evi_code"),
+			DownstreamCodePC: r.intPtr("downstream_code_pc"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		alertes = append(alertes, a)
+		return nil
+	})
+	return alertes, err
+}
+
+// LoadDefauts implémente DataSource
+func (c *CSVSource) LoadDefauts(ctx context.Context, rej *Rejects) ([]models.Defaut, error) {
+	var defauts []models.Defaut
+	err := c.readTable(ctx, TableDefauts, rej, []string{
+		"site", "date_debut", "date_fin", "defaut", "eqp",
+	}, func(r *csvRow) error {
+		d := models.Defaut{
+			Site:       r.str("site"),
+			DateDebut:  r.time("date_debut"),
+			DateFin:    r.timePtr("date_fin"),
+			Defaut:     r.str("defaut"),
+			Equipement: r.str("eqp"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		defauts = append(defauts, d)
+		return nil
+	})
+	return defauts, err
+}
+
+// LoadSuspicious implémente DataSource
+func (c *CSVSource) LoadSuspicious(ctx context.Context, rej *Rejects) ([]models.SuspiciousTransaction, error) {
+	var suspicious []models.SuspiciousTransaction
+	err := c.readTable(ctx, TableSuspicious, rej, []string{
+		"ID", "Site", "PDC", "MAC Address", "Vehicle", "Datetime start", "Datetime end",
+		"Energy (Kwh)", "SOC Start", "SOC End",
+	}, func(r *csvRow) error {
+		s := models.SuspiciousTransaction{
+			ID:            r.str("ID"),
+			Site:          r.str("Site"),
+			PDC:           r.str("PDC"),
+			MACAddress:    r.str("MAC Address"),
+			Vehicle:       r.str("Vehicle"),
+			DatetimeStart: r.time("Datetime start"),
+			DatetimeEnd:   r.timePtr("Datetime end"),
+			EnergyKwh:     r.float("Energy (Kwh)"),
+			SOCStart:      r.floatPtr("SOC Start"),
+			SOCEnd:        r.floatPtr("SOC End"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		suspicious = append(suspicious, s)
+		return nil
+	})
+	return suspicious, err
+}
+
+// LoadMultiAttempts implémente DataSource
+func (c *CSVSource) LoadMultiAttempts(ctx context.Context, rej *Rejects) ([]models.MultiAttempt, error) {
+	var attempts []models.MultiAttempt
+	err := c.readTable(ctx, TableMultiAttempts, rej, []string{
+		"Site", "Heure", "MAC", "Vehicle", "tentatives", "PDC(s)", "1ère tentative",
+		"Dernière tentative", "ID(s)", "SOC start min", "SOC start max", "SOC end min", "SOC end max",
+	}, func(r *csvRow) error {
+		m := models.MultiAttempt{
+			Site:              r.str("Site"),
+			Heure:             r.str("Heure"),
+			MAC:               r.str("MAC"),
+			Vehicle:           r.str("Vehicle"),
+			Tentatives:        r.int("tentatives"),
+			PDCs:              r.str("PDC(s)"),
+			PremiereTentative: r.time("1ère tentative"),
+			DerniereTentative: r.time("Dernière tentative"),
+			IDs:               r.str("ID(s)"),
+			SOCStartMin:       r.floatPtr("SOC start min"),
+			SOCStartMax:       r.floatPtr("SOC start max"),
+			SOCEndMin:         r.floatPtr("SOC end min"),
+			SOCEndMax:         r.floatPtr("SOC end max"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		attempts = append(attempts, m)
+		return nil
+	})
+	return attempts, err
+}
+
+// LoadChargesMAC implémente DataSource
+func (c *CSVSource) LoadChargesMAC(ctx context.Context, rej *Rejects) ([]models.ChargeMAC, error) {
+	var charges []models.ChargeMAC
+	err := c.readTable(ctx, TableChargesMAC, rej, []string{
+		"ID", "Site", "MAC Address", "Vehicle", "Datetime start", "SOC Start", "SOC End", "is_ok",
+	}, func(r *csvRow) error {
+		ch := models.ChargeMAC{
+			ID:            r.str("ID"),
+			Site:          r.str("Site"),
+			MACAddress:    r.str("MAC Address"),
+			Vehicle:       r.str("Vehicle"),
+			DatetimeStart: r.time("Datetime start"),
+			SOCStart:      r.floatPtr("SOC Start"),
+			SOCEnd:        r.floatPtr("SOC End"),
+			IsOK:          r.bool("is_ok"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		charges = append(charges, ch)
+		return nil
+	})
+	return charges, err
+}
+
+// LoadStatsGlobal implémente DataSource
+func (c *CSVSource) LoadStatsGlobal(ctx context.Context, rej *Rejects) ([]models.StatsGlobal, error) {
+	var stats []models.StatsGlobal
+	err := c.readTable(ctx, TableEvo, rej, []string{"mois", "tr"}, func(r *csvRow) error {
+		s := models.StatsGlobal{
+			Mois:         r.str("mois"),
+			TauxReussite: r.float("tr"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		stats = append(stats, s)
+		return nil
+	})
+	return stats, err
+}
+
+// LoadChargesDaily implémente DataSource
+func (c *CSVSource) LoadChargesDaily(ctx context.Context, rej *Rejects) ([]models.ChargesDaily, error) {
+	var charges []models.ChargesDaily
+	err := c.readTable(ctx, TableChargesDaily, rej, []string{"Site", "day", "Status", "Nb"}, func(r *csvRow) error {
+		ch := models.ChargesDaily{
+			Site:   r.str("Site"),
+			Day:    r.time("day"),
+			Status: r.str("Status"),
+			Nb:     r.int("Nb"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		charges = append(charges, ch)
+		return nil
+	})
+	return charges, err
+}
+
+// LoadDurationsSiteDaily implémente DataSource
+func (c *CSVSource) LoadDurationsSiteDaily(ctx context.Context, rej *Rejects) ([]models.DurationsSiteDaily, error) {
+	var durations []models.DurationsSiteDaily
+	err := c.readTable(ctx, TableDurationsSiteDaily, rej, []string{"Site", "day", "dur_min"}, func(r *csvRow) error {
+		d := models.DurationsSiteDaily{
+			Site:   r.str("Site"),
+			Day:    r.time("day"),
+			DurMin: r.float("dur_min"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		durations = append(durations, d)
+		return nil
+	})
+	return durations, err
+}
+
+// LoadDurationsPDCDaily implémente DataSource
+func (c *CSVSource) LoadDurationsPDCDaily(ctx context.Context, rej *Rejects) ([]models.DurationsPDCDaily, error) {
+	var durations []models.DurationsPDCDaily
+	err := c.readTable(ctx, TableDurationsPDCDaily, rej, []string{"Site", "PDC", "day", "dur_min"}, func(r *csvRow) error {
+		d := models.DurationsPDCDaily{
+			Site:   r.str("Site"),
+			PDC:    r.str("PDC"),
+			Day:    r.time("day"),
+			DurMin: r.float("dur_min"),
+		}
+		if r.err != nil {
+			return r.err
+		}
+		durations = append(durations, d)
+		return nil
+	})
+	return durations, err
+}
+
+// readTable ouvre <dir>/<table>.csv, vérifie l'en-tête et appelle fn pour
+// chaque ligne. Les lignes en erreur sont rejetées et comptabilisées.
+func (c *CSVSource) readTable(ctx context.Context, table string, rej *Rejects, required []string, fn func(*csvRow) error) error {
+	f, err := os.Open(filepath.Join(c.dir, table+".csv"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readCSV(ctx, table, f, rej, required, fn)
+}
+
+// readCSV lit une table CSV depuis r ; voir readTable
+func readCSV(ctx context.Context, table string, r io.Reader, rej *Rejects, required []string, fn func(*csvRow) error) error {
+	br := bufio.NewReader(r)
+	sep, err := detectSeparator(br)
+	if err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = sep
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: error reading header: %w", table, err)
+	}
+
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		cols[name] = i
+	}
+	for _, name := range required {
+		if _, ok := cols[name]; !ok {
+			return fmt.Errorf("%s: missing column %q", table, name)
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var line int
+		if err == nil {
+			line, _ = reader.FieldPos(0)
+			err = fn(&csvRow{cols: cols, rec: rec, decimalComma: sep == ';'})
+		} else {
+			// Seule une ligne mal formée est écartée ; une erreur de
+			// lecture fait échouer la table
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return fmt.Errorf("%s: error reading rows: %w", table, err)
+			}
+			line = parseErr.Line
+		}
+		if err != nil {
+			rej.Add(line, err)
+		}
+	}
+
+	return nil
+}
+
+// detectSeparator choisit ',' ou ';' d'après la ligne d'en-tête
+func detectSeparator(br *bufio.Reader) (rune, error) {
+	peek, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return 0, err
+	}
+	if len(peek) == 0 {
+		return 0, errors.New("empty file")
+	}
+
+	first := string(peek)
+	if i := strings.IndexByte(first, '\n'); i >= 0 {
+		first = first[:i]
+	}
+	if strings.Count(first, ";") > strings.Count(first, ",") {
+		return ';', nil
+	}
+	return ',', nil
+}
+
+// csvLayouts sont les formats de date acceptés (exports MySQL, pandas, Excel)
+var csvLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"2006-01-02",
+	"02/01/2006",
+}
+
+// csvRow donne un accès typé aux colonnes d'une ligne ; la première erreur
+// de conversion est conservée dans err
+type csvRow struct {
+	cols         map[string]int
+	rec          []string
+	decimalComma bool
+	err          error
+}
+
+func (r *csvRow) fail(col string, err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("column %q: %w", col, err)
+	}
+}
+
+func (r *csvRow) raw(col string) (string, bool) {
+	i, ok := r.cols[col]
+	if !ok || i >= len(r.rec) {
+		return "", false
+	}
+	v := strings.TrimSpace(r.rec[i])
+	switch strings.ToUpper(v) {
+	case "", "NULL", "NAN", "NAT", "NONE":
+		return "", false
+	}
+	return v, true
+}
+
+func (r *csvRow) str(col string) string {
+	v, _ := r.raw(col)
+	return v
+}
+
+// coalesce reproduit COALESCE(a, b, ...) du SQL
+func (r *csvRow) coalesce(cols ...string) string {
+	for _, col := range cols {
+		if v, ok := r.raw(col); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func (r *csvRow) timePtr(col string) *time.Time {
+	v, ok := r.raw(col)
+	if !ok {
+		return nil
+	}
+	for _, layout := range csvLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t
+		}
+	}
+	r.fail(col, fmt.Errorf("invalid datetime %q", v))
+	return nil
+}
+
+func (r *csvRow) time(col string) time.Time {
+	if _, ok := r.raw(col); !ok {
+		r.fail(col, errors.New("value is required"))
+		return time.Time{}
+	}
+	if t := r.timePtr(col); t != nil {
+		return *t
+	}
+	return time.Time{}
+}
+
+func (r *csvRow) floatPtr(col string) *float64 {
+	v, ok := r.raw(col)
+	if !ok {
+		return nil
+	}
+	if r.decimalComma && !strings.Contains(v, ".") {
+		v = strings.Replace(v, ",", ".", 1)
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		r.fail(col, fmt.Errorf("invalid number %q", v))
+		return nil
+	}
+	return &f
+}
+
+func (r *csvRow) float(col string) float64 {
+	if _, ok := r.raw(col); !ok {
+		r.fail(col, errors.New("value is required"))
+		return 0
+	}
+	if f := r.floatPtr(col); f != nil {
+		return *f
+	}
+	return 0
+}
+
+// intPtr accepte aussi "12.0" (colonnes entières nullables exportées par pandas)
+func (r *csvRow) intPtr(col string) *int {
+	v, ok := r.raw(col)
+	if !ok {
+		return nil
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return &n
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f != float64(int(f)) {
+		r.fail(col, fmt.Errorf("invalid integer %q", v))
+		return nil
+	}
+	n := int(f)
+	return &n
+}
+
+func (r *csvRow) int(col string) int {
+	if _, ok := r.raw(col); !ok {
+		r.fail(col, errors.New("value is required"))
+		return 0
+	}
+	if n := r.intPtr(col); n != nil {
+		return *n
+	}
+	return 0
+}
+
+func (r *csvRow) bool(col string) bool {
+	v, _ := r.raw(col)
+	switch strings.ToLower(v) {
+	case "1", "1.0", "true", "vrai", "ok":
+		return true
+	case "0", "0.0", "false", "faux", "nok", "":
+		return false
+	}
+	r.fail(col, fmt.Errorf("invalid boolean %q", v))
+	return false
+}
+
+// Vérification à la compilation
+var _ DataSource = (*CSVSource)(nil)
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func fp(v float64) *float64 { return &v }
+
+func ip(v int) *int { return &v }
+
+func TestDetectSeparator(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    rune
+		wantErr bool
+	}{
+		{name: "virgule", input: "Site,PDC,detection\nA;B,C,D\n", want: ','},
+		{name: "point-virgule", input: "Site;PDC;Energy (Kwh)\nA;1;12,5\n", want: ';'},
+		{name: "en-tête seul", input: "Site;PDC", want: ';'},
+		{name: "égalité", input: "a,b;c\n", want: ','},
+		{name: "vide", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectSeparator(bufio.NewReader(strings.NewReader(tt.input)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("detectSeparator error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("detectSeparator = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVRowValues(t *testing.T) {
+	row := func(v string, decimalComma bool) *csvRow {
+		return &csvRow{cols: map[string]int{"v": 0}, rec: []string{v}, decimalComma: decimalComma}
+	}
+
+	floats := []struct {
+		in           string
+		decimalComma bool
+		want         *float64
+		wantErr      bool
+	}{
+		{in: "12.5", want: fp(12.5)},
+		{in: "12,5", decimalComma: true, want: fp(12.5)},
+		{in: "12.5", decimalComma: true, want: fp(12.5)},
+		{in: "12,5", wantErr: true},
+		{in: "1,234.5", decimalComma: true, wantErr: true},
+		{in: " 7 ", want: fp(7)},
+		{in: ""},
+		{in: "NULL"},
+		{in: "nan"},
+		{in: "NaT"},
+		{in: "None"},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range floats {
+		r := row(tt.in, tt.decimalComma)
+		got := r.floatPtr("v")
+		if (r.err != nil) != tt.wantErr {
+			t.Errorf("floatPtr(%q, decimalComma=%v) error = %v, wantErr %v", tt.in, tt.decimalComma, r.err, tt.wantErr)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("floatPtr(%q, decimalComma=%v) = %v, want %v", tt.in, tt.decimalComma, got, tt.want)
+		}
+	}
+
+	ints := []struct {
+		in      string
+		want    *int
+		wantErr bool
+	}{
+		{in: "12", want: ip(12)},
+		{in: "12.0", want: ip(12)},
+		{in: "12.5", wantErr: true},
+		{in: "NONE"},
+		{in: "x", wantErr: true},
+	}
+	for _, tt := range ints {
+		r := row(tt.in, false)
+		got := r.intPtr("v")
+		if (r.err != nil) != tt.wantErr {
+			t.Errorf("intPtr(%q) error = %v, wantErr %v", tt.in, r.err, tt.wantErr)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("intPtr(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	times := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2024-03-01 08:30:00", want: time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{in: "2024-03-01T08:30:00", want: time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{in: "01/03/2024 08:30", want: time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+		{in: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "NaT", wantErr: true},
+		{in: "hier", wantErr: true},
+	}
+	for _, tt := range times {
+		r := row(tt.in, false)
+		got := r.time("v")
+		if (r.err != nil) != tt.wantErr {
+			t.Errorf("time(%q) error = %v, wantErr %v", tt.in, r.err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("time(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAlertesCSV(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		wantRows     int
+		wantRejected int
+		wantLines    []int
+		wantErr      string
+	}{
+		{
+			name: "virgule",
+			content: "Site,PDC,type_erreur,detection,occurrences_12h,moment,evi_code,downstream_code_pc\n" +
+				"A,A1,Erreur_EVI,2024-03-01 08:30:00,3,Init,12,\n" +
+				"B,B1,Erreur_DownStream,2024-03-01 09:00:00,4.0,Charge,NULL,8192.0\n",
+			wantRows: 2,
+		},
+		{
+			name: "point-virgule et lignes rejetées",
+			content: "Site;PDC;type_erreur;detection;occurrences_12h;moment;evi_code;downstream_code_pc\n" +
+				"A;A1;Erreur_EVI;2024-03-01 08:30:00;3;Init;12;\n" +
+				"A;A1;Erreur_EVI;pas une date;3;Init;12;\n" +
+				"B;B1;Erreur_EVI;2024-03-01 09:00:00;beaucoup;Init;;\n" +
+				"B;B2;Erreur_EVI;2024-03-01 10:00:00;1;Init;nan;None\n",
+			wantRows:     2,
+			wantRejected: 2,
+			wantLines:    []int{3, 4},
+		},
+		{
+			name: "guillemet mal formé",
+			content: "Site,PDC,type_erreur,detection,occurrences_12h,moment,evi_code,downstream_code_pc\n" +
+				"A,A1,Erreur_EVI,2024-03-01 08:30:00,3,Init,12,\n" +
+				"A,\"A1,Erreur_EVI,2024-03-01 08:30:00,3,Init,12,\n",
+			wantRows:     1,
+			wantRejected: 1,
+		},
+		{
+			name:    "colonne manquante",
+			content: "Site,PDC,type_erreur,detection\nA,A1,Erreur_EVI,2024-03-01 08:30:00\n",
+			wantErr: `missing column "occurrences_12h"`,
+		},
+		{
+			name:    "fichier vide",
+			content: "",
+			wantErr: "empty file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, TableAlertes+".csv"), []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			rej := &Rejects{Table: TableAlertes}
+			rows, err := NewCSVSource(dir).LoadAlertes(context.Background(), rej)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadAlertes error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadAlertes: %v", err)
+			}
+			if len(rows) != tt.wantRows || rej.Count != tt.wantRejected {
+				t.Errorf("rows = %d, rejected = %d, want %d and %d (%v)", len(rows), rej.Count, tt.wantRows, tt.wantRejected, rej.Samples)
+			}
+			for i, line := range tt.wantLines {
+				if i < len(rej.Samples) && rej.Samples[i].Line != line {
+					t.Errorf("reject %d line = %d, want %d", i, rej.Samples[i].Line, line)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSessionsDecimalComma(t *testing.T) {
+	dir := t.TempDir()
+	content := "ID;Datetime start;Datetime end;Site;Name Project;PDC;State of charge(0:good, 1:error);type_erreur;moment;" +
+		"moment_avancee;EVI Error Code;EVI Status during error;Downstream Code PC;Energy (Kwh);Mean Power (Kw);" +
+		"Max Power (Kw);SOC Start;SOC End;MAC Address;charge_900V\n" +
+		"s1;2024-03-01 08:30:00;NaT;NULL;Projet A;P1;0;;;;;;;12,5;40;55,25;20;80;aa:bb;0\n"
+	if err := os.WriteFile(filepath.Join(dir, TableSessions+".csv"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := NewCSVSource(dir).LoadSessions(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("LoadSessions: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("rows = %d, want 1", len(rows))
+	}
+	s := rows[0]
+	if s.Site != "Projet A" || s.DatetimeEnd != nil || s.EVIErrorCode != nil {
+		t.Errorf("site = %q, end = %v, evi = %v: want coalesced site and NULL values", s.Site, s.DatetimeEnd, s.EVIErrorCode)
+	}
+	if s.EnergyKwh == nil || *s.EnergyKwh != 12.5 || s.MaxPowerKw == nil || *s.MaxPowerKw != 55.25 {
+		t.Errorf("energy = %v, max power = %v, want 12.5 and 55.25", s.EnergyKwh, s.MaxPowerKw)
+	}
+}
+
+// Une erreur de lecture (fichier tronqué, NFS) fait échouer la table au
+// lieu d'être comptée comme des lignes rejetées
+func TestReadCSVReadError(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("Site,PDC\n")
+	for b.Len() < 8192 {
+		b.WriteString("A,A1\n")
+	}
+	ioErr := errors.New("stale NFS file handle")
+	r := io.MultiReader(strings.NewReader(b.String()), iotest.ErrReader(ioErr))
+
+	rej := &Rejects{Table: "t"}
+	rows := 0
+	err := readCSV(context.Background(), "t", r, rej, []string{"Site"}, func(*csvRow) error {
+		rows++
+		return nil
+	})
+	if !errors.Is(err, ioErr) {
+		t.Fatalf("readCSV error = %v, want %v", err, ioErr)
+	}
+	if rows == 0 || rej.Count != 0 {
+		t.Errorf("rows = %d, rejected = %d, want rows read before the error and no rejects", rows, rej.Count)
+	}
+}
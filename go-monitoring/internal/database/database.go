@@ -1,37 +1,76 @@
 package database
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/config"
+	"github.com/monitoring/charging-stations/internal/memo"
 	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/rollup"
+	"github.com/monitoring/charging-stations/internal/store"
 )
 
-// DB représente la connexion à la base de données
+// DB fédère une ou plusieurs bases KPI dans un même cache. Chaque base est
+// rafraîchie indépendamment ; leurs instantanés sont fusionnés puis publiés
+// ensemble.
 type DB struct {
-	conn  *sql.DB
-	cache *Cache
-	mu    sync.RWMutex
+	cfg      config.DatabaseConfig
+	cacheCfg config.CacheConfig
+	members  []*member
+
+	// cache pointe vers le dernier instantané fusionné publié ; il n'est
+	// jamais modifié après publication, seulement remplacé
+	cache  atomic.Pointer[Cache]
+	report atomic.Pointer[RefreshReport]
+
+	// publishMu sérialise la fusion des instantanés ; les lectures n'en
+	// dépendent pas
+	publishMu sync.Mutex
+
+	// saveMu sérialise les sauvegardes sur disque, hors de publishMu pour
+	// qu'un disque lent ne retarde pas les publications
+	saveMu sync.Mutex
+
+	// memo mémorise les résultats filtrés ; il est vidé à chaque publication
+	memo *memo.LRU
+
+	// ctx borne les tâches de fond lancées après coup (reconnexions)
+	ctx context.Context
 }
 
-// Cache pour les données KPI
+// Cache est un instantané immuable des données KPI. Un handler récupère un
+// instantané via DB.Snapshot() et toutes ses lectures restent cohérentes,
+// même si un refresh publie une nouvelle version entre-temps.
 type Cache struct {
-	sessions              []models.Session
-	alertes               []models.Alerte
-	defauts               []models.Defaut
-	suspicious            []models.SuspiciousTransaction
-	multiAttempts         []models.MultiAttempt
-	chargesMAC            []models.ChargeMAC
-	statsGlobal           []models.StatsGlobal
-	chargesDaily          []models.ChargesDaily
-	durationsSiteDaily    []models.DurationsSiteDaily
-	durationsPDCDaily     []models.DurationsPDCDaily
-	lastUpdate            time.Time
-	mu                    sync.RWMutex
+	version            uint64
+	sessions           *columnar.Sessions
+	alertes            []models.Alerte
+	defauts            []models.Defaut
+	suspicious         []models.SuspiciousTransaction
+	multiAttempts      []models.MultiAttempt
+	chargesMAC         []models.ChargeMAC
+	statsGlobal        []models.StatsGlobal
+	chargesDaily       []models.ChargesDaily
+	durationsSiteDaily []models.DurationsSiteDaily
+	durationsPDCDaily  []models.DurationsPDCDaily
+	lastUpdate         time.Time
+	// sessionIndex indexe les sessions de l'instantané fusionné
+	sessionIndex *store.Sessions
+	// cube pré-agrège les sessions de l'instantané fusionné
+	cube *rollup.Cube
+	// memo est le LRU partagé des résultats filtrés, indexé par version
+	memo *memo.LRU
+	// restoredAt date la sauvegarde disque dont provient l'instantané
+	// (zéro s'il a été construit par un refresh)
+	restoredAt time.Time
 }
 
 var (
@@ -39,485 +78,364 @@ var (
 	once     sync.Once
 )
 
-// GetDB retourne l'instance singleton de la base de données
-func GetDB() *DB {
+// GetDB retourne l'instance singleton qui fédère les bases configurées.
+// Les connexions MySQL sont établies en arrière-plan : le serveur démarre
+// même si une base est injoignable et sert en attendant les données dont il
+// dispose.
+func GetDB(ctx context.Context, cfg config.Config) *DB {
 	once.Do(func() {
-		instance = &DB{
-			cache: &Cache{},
+		var members []*member
+		for _, src := range cfg.Database.SourceList() {
+			dbCfg := cfg.Database
+			dbCfg.DSN = src.DSN
+
+			var source DataSource
+			if src.CSVDir != "" {
+				log.Printf("📂 Loading KPI tables from CSV snapshot %s", src.CSVDir)
+				source = NewCSVSource(src.CSVDir)
+			}
+			members = append(members, newMember(src.Name, source, dbCfg))
 		}
-		if err := instance.Connect(); err != nil {
-			log.Fatal("Failed to connect to database:", err)
+
+		instance = newDB(ctx, cfg.Database, cfg.Cache, members)
+		for _, m := range members {
+			if m.source != nil {
+				go instance.refreshMember(ctx, m)
+			} else {
+				go instance.connectLoop(ctx, m)
+			}
 		}
 	})
 	return instance
 }
 
-// Connect établit la connexion à MySQL
-func (db *DB) Connect() error {
-	dsn := "nidec:MaV38f5xsGQp83@tcp(162.19.251.55:3306)/Charges?parseTime=true"
-
-	conn, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return fmt.Errorf("error opening database: %w", err)
-	}
-
-	// Configuration de la connexion
-	conn.SetMaxOpenConns(25)
-	conn.SetMaxIdleConns(5)
-	conn.SetConnMaxLifetime(5 * time.Minute)
-
-	// Test de connexion
-	if err := conn.Ping(); err != nil {
-		return fmt.Errorf("error pinging database: %w", err)
-	}
-
-	db.conn = conn
-	log.Println("✅ Connected to MySQL database")
-
-	// Charger le cache initial
-	go db.RefreshCache()
-
-	return nil
+// NewWithSource crée une instance alimentée par une source quelconque
+// (fixtures, snapshots, autre moteur) sans passer par MySQL. Le chargement
+// initial est annulé avec ctx.
+func NewWithSource(ctx context.Context, source DataSource, cfg config.CacheConfig) *DB {
+	m := newMember("", source, config.DatabaseConfig{})
+	db := newDB(ctx, config.DatabaseConfig{}, cfg, []*member{m})
+	go db.refreshMember(ctx, m)
+	return db
 }
 
-// RefreshCache recharge toutes les données KPI
-func (db *DB) RefreshCache() error {
-	db.cache.mu.Lock()
-	defer db.cache.mu.Unlock()
-
-	log.Println("🔄 Refreshing cache...")
-
-	// Charger les sessions
-	sessions, err := db.loadSessions()
-	if err != nil {
-		log.Printf("Error loading sessions: %v", err)
-	} else {
-		db.cache.sessions = sessions
-	}
-
-	// Charger les alertes
-	alertes, err := db.loadAlertes()
-	if err != nil {
-		log.Printf("Error loading alertes: %v", err)
-	} else {
-		db.cache.alertes = alertes
-	}
-
-	// Charger les défauts
-	defauts, err := db.loadDefauts()
-	if err != nil {
-		log.Printf("Error loading defauts: %v", err)
-	} else {
-		db.cache.defauts = defauts
-	}
-
-	// Charger les transactions suspectes
-	suspicious, err := db.loadSuspicious()
-	if err != nil {
-		log.Printf("Error loading suspicious: %v", err)
-	} else {
-		db.cache.suspicious = suspicious
-	}
-
-	// Charger les tentatives multiples
-	multiAttempts, err := db.loadMultiAttempts()
-	if err != nil {
-		log.Printf("Error loading multi attempts: %v", err)
-	} else {
-		db.cache.multiAttempts = multiAttempts
-	}
-
-	// Charger charges_mac
-	chargesMAC, err := db.loadChargesMAC()
-	if err != nil {
-		log.Printf("Error loading charges_mac: %v", err)
-	} else {
-		db.cache.chargesMAC = chargesMAC
-	}
-
-	// Charger stats globales
-	statsGlobal, err := db.loadStatsGlobal()
-	if err != nil {
-		log.Printf("Error loading stats global: %v", err)
-	} else {
-		db.cache.statsGlobal = statsGlobal
-	}
-
-	// Charger charges daily
-	chargesDaily, err := db.loadChargesDaily()
-	if err != nil {
-		log.Printf("Error loading charges daily: %v", err)
-	} else {
-		db.cache.chargesDaily = chargesDaily
-	}
+func newDB(ctx context.Context, dbCfg config.DatabaseConfig, cacheCfg config.CacheConfig, members []*member) *DB {
+	db := &DB{cfg: dbCfg, cacheCfg: cacheCfg, members: members, memo: memo.New(cacheCfg.MemoEntries), ctx: ctx}
+	db.cache.Store(&Cache{})
+	db.restoreSnapshot()
+	return db
+}
 
-	// Charger durées site daily
-	durationsSiteDaily, err := db.loadDurationsSiteDaily()
-	if err != nil {
-		log.Printf("Error loading durations site daily: %v", err)
-	} else {
-		db.cache.durationsSiteDaily = durationsSiteDaily
-	}
+// Snapshot retourne l'instantané courant du cache
+func (db *DB) Snapshot() *Cache {
+	return db.cache.Load()
+}
 
-	// Charger durées PDC daily
-	durationsPDCDaily, err := db.loadDurationsPDCDaily()
-	if err != nil {
-		log.Printf("Error loading durations pdc daily: %v", err)
-	} else {
-		db.cache.durationsPDCDaily = durationsPDCDaily
+// Sources retourne les noms des bases fédérées (un seul nom vide sans
+// fédération)
+func (db *DB) Sources() []string {
+	names := make([]string, len(db.members))
+	for i, m := range db.members {
+		names[i] = m.name
 	}
-
-	db.cache.lastUpdate = time.Now()
-	log.Println("✅ Cache refreshed successfully")
-
-	return nil
+	return names
 }
 
-// loadSessions charge les sessions depuis la table kpi_sessions
-func (db *DB) loadSessions() ([]models.Session, error) {
-	query := "SELECT ID, `Datetime start`, `Datetime end`, COALESCE(Site, `Name Project`) as Site, " +
-		"PDC, `State of charge(0:good, 1:error)`, type_erreur, moment, moment_avancee, " +
-		"`EVI Error Code`, `EVI Status during error`, `Downstream Code PC`, `Energy (Kwh)`, " +
-		"`Mean Power (Kw)`, `Max Power (Kw)`, `SOC Start`, `SOC End`, `MAC Address`, charge_900V " +
-		"FROM kpi_sessions"
+// RefreshCache rafraîchit toutes les bases en parallèle, chacune
+// indépendamment, puis retourne le rapport fusionné. L'erreur regroupe
+// celles des bases en échec.
+func (db *DB) RefreshCache(ctx context.Context) (RefreshReport, error) {
+	errs := make([]error, len(db.members))
+
+	var wg sync.WaitGroup
+	for i, m := range db.members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			if err := db.refreshMember(ctx, m); err != nil && m.name != "" {
+				errs[i] = fmt.Errorf("%s: %w", m.name, err)
+			} else {
+				errs[i] = err
+			}
+		}(i, m)
+	}
+	wg.Wait()
+
+	return db.lastReportValue(), errors.Join(errs...)
+}
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var sessions []models.Session
-	for rows.Next() {
-		var s models.Session
-		err := rows.Scan(
-			&s.ID,
-			&s.DatetimeStart,
-			&s.DatetimeEnd,
-			&s.Site,
-			&s.PDC,
-			&s.StateOfCharge,
-			&s.TypeErreur,
-			&s.Moment,
-			&s.MomentAvancee,
-			&s.EVIErrorCode,
-			&s.EVIMomentStep,
-			&s.DownstreamCodePC,
-			&s.EnergyKwh,
-			&s.MeanPowerKw,
-			&s.MaxPowerKw,
-			&s.SOCStart,
-			&s.SOCEnd,
-			&s.MACAddress,
-			&s.Charge900V,
-		)
-		if err != nil {
-			log.Printf("Error scanning session: %v", err)
-			continue
+// RefreshSource rafraîchit une seule base fédérée
+func (db *DB) RefreshSource(ctx context.Context, name string) (RefreshReport, error) {
+	for _, m := range db.members {
+		if m.name == name {
+			err := db.refreshMember(ctx, m)
+			return db.lastReportValue(), err
 		}
-		sessions = append(sessions, s)
 	}
-
-	return sessions, nil
+	return db.lastReportValue(), fmt.Errorf("unknown source %q", name)
 }
 
-// loadAlertes charge les alertes
-func (db *DB) loadAlertes() ([]models.Alerte, error) {
-	query := `SELECT Site, PDC, type_erreur, detection, occurrences_12h, moment, evi_code, downstream_code_pc
-		FROM kpi_alertes ORDER BY detection DESC`
-
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
+// refreshMember rafraîchit une base puis republie le cache fusionné. Le
+// cache n'est sauvegardé que si la base a été entièrement rechargée.
+func (db *DB) refreshMember(ctx context.Context, m *member) error {
+	_, err := m.refresh(ctx, db.cacheCfg)
+	if errors.Is(err, ErrNotConnected) || ctx.Err() != nil {
+		return err
 	}
-	defer rows.Close()
-
-	var alertes []models.Alerte
-	for rows.Next() {
-		var a models.Alerte
-		err := rows.Scan(&a.Site, &a.PDC, &a.TypeErreur, &a.Detection, &a.Occurrences12h,
-			&a.Moment, &a.EVICode, &a.DownstreamCodePC)
-		if err != nil {
-			continue
-		}
-		alertes = append(alertes, a)
+	// La reconnexion survit à ctx, qui peut n'être que celui d'une requête
+	if errors.Is(err, ErrConnectionLost) {
+		go db.connectLoop(db.ctx, m)
 	}
 
-	return alertes, nil
+	db.publish()
+	if err == nil {
+		db.persistSnapshot()
+	}
+	return err
 }
 
-// loadDefauts charge les défauts
-func (db *DB) loadDefauts() ([]models.Defaut, error) {
-	query := `SELECT site, date_debut, date_fin, defaut, eqp
-		FROM kpi_defauts_log ORDER BY date_debut DESC`
+// publish fusionne les derniers instantanés des bases et publie le
+// résultat avec un rapport regroupant leurs tables
+func (db *DB) publish() {
+	db.publishMu.Lock()
+	defer db.publishMu.Unlock()
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
+	merged := mergeCaches(db.members)
+	merged.memo = db.memo
+	db.cache.Store(merged)
+	db.memo.Purge()
+	if report := mergeReports(db.members, merged.version); report != nil {
+		db.report.Store(report)
 	}
-	defer rows.Close()
+}
 
-	var defauts []models.Defaut
-	for rows.Next() {
-		var d models.Defaut
-		err := rows.Scan(&d.Site, &d.DateDebut, &d.DateFin, &d.Defaut, &d.Equipement)
-		if err != nil {
-			continue
+// mergeCaches concatène les instantanés des bases puis indexe les
+// sessions. La version fusionnée est la somme des versions : elle augmente
+// dès qu'une base publie.
+func mergeCaches(members []*member) *Cache {
+	if len(members) == 1 {
+		c := *members[0].cache.Load()
+		c.sessionIndex = store.New(c.sessions)
+		c.sessions = c.sessionIndex.Columns()
+		c.cube = rollup.Build(c.sessions.All())
+		return &c
+	}
+
+	merged := &Cache{}
+	parts := make([]*columnar.Sessions, 0, len(members))
+	for _, m := range members {
+		c := m.cache.Load()
+		merged.version += c.version
+		parts = append(parts, c.sessions)
+		merged.alertes = append(merged.alertes, c.alertes...)
+		merged.defauts = append(merged.defauts, c.defauts...)
+		merged.suspicious = append(merged.suspicious, c.suspicious...)
+		merged.multiAttempts = append(merged.multiAttempts, c.multiAttempts...)
+		merged.chargesMAC = append(merged.chargesMAC, c.chargesMAC...)
+		merged.statsGlobal = append(merged.statsGlobal, c.statsGlobal...)
+		merged.chargesDaily = append(merged.chargesDaily, c.chargesDaily...)
+		merged.durationsSiteDaily = append(merged.durationsSiteDaily, c.durationsSiteDaily...)
+		merged.durationsPDCDaily = append(merged.durationsPDCDaily, c.durationsPDCDaily...)
+		if c.lastUpdate.After(merged.lastUpdate) {
+			merged.lastUpdate = c.lastUpdate
+		}
+		// Une base encore servie depuis la sauvegarde marque tout l'instantané
+		if !c.restoredAt.IsZero() && (merged.restoredAt.IsZero() || c.restoredAt.Before(merged.restoredAt)) {
+			merged.restoredAt = c.restoredAt
 		}
-		defauts = append(defauts, d)
 	}
 
-	return defauts, nil
-}
-
-// loadSuspicious charge les transactions suspectes
-func (db *DB) loadSuspicious() ([]models.SuspiciousTransaction, error) {
-	query := "SELECT ID, Site, PDC, `MAC Address`, Vehicle, `Datetime start`, `Datetime end`, " +
-		"`Energy (Kwh)`, `SOC Start`, `SOC End` FROM kpi_suspicious_under_1kwh"
+	merged.sessionIndex = store.New(parts...)
+	merged.sessions = merged.sessionIndex.Columns()
+	merged.cube = rollup.Build(merged.sessions.All())
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return merged
+}
 
-	var suspicious []models.SuspiciousTransaction
-	for rows.Next() {
-		var s models.SuspiciousTransaction
-		err := rows.Scan(&s.ID, &s.Site, &s.PDC, &s.MACAddress, &s.Vehicle,
-			&s.DatetimeStart, &s.DatetimeEnd, &s.EnergyKwh, &s.SOCStart, &s.SOCEnd)
-		if err != nil {
+// mergeReports regroupe les rapports des bases (nil si aucune n'a encore
+// de rapport)
+func mergeReports(members []*member, version uint64) *RefreshReport {
+	var merged *RefreshReport
+	var sources []string
+	for _, m := range members {
+		r := m.report.Load()
+		if r == nil {
 			continue
 		}
-		suspicious = append(suspicious, s)
-	}
-
-	return suspicious, nil
-}
-
-// loadMultiAttempts charge les tentatives multiples
-func (db *DB) loadMultiAttempts() ([]models.MultiAttempt, error) {
-	query := "SELECT Site, Heure, MAC, Vehicle, tentatives, `PDC(s)`, " +
-		"`1ère tentative`, `Dernière tentative`, `ID(s)`, " +
-		"`SOC start min`, `SOC start max`, `SOC end min`, `SOC end max` " +
-		"FROM kpi_multi_attempts_hour"
+		if merged == nil {
+			merged = &RefreshReport{StartedAt: r.StartedAt}
+		}
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var attempts []models.MultiAttempt
-	for rows.Next() {
-		var m models.MultiAttempt
-		err := rows.Scan(&m.Site, &m.Heure, &m.MAC, &m.Vehicle, &m.Tentatives, &m.PDCs,
-			&m.PremiereTentative, &m.DerniereTentative, &m.IDs,
-			&m.SOCStartMin, &m.SOCStartMax, &m.SOCEndMin, &m.SOCEndMax)
-		if err != nil {
-			continue
+		if m.name == "" {
+			sources = append(sources, r.Source)
+		} else {
+			sources = append(sources, m.name+"="+r.Source)
 		}
-		attempts = append(attempts, m)
+		if r.StartedAt.Before(merged.StartedAt) {
+			merged.StartedAt = r.StartedAt
+		}
+		merged.DurationMs = max(merged.DurationMs, r.DurationMs)
+		merged.Tables = append(merged.Tables, r.Tables...)
+	}
+	if merged == nil {
+		return nil
 	}
 
-	return attempts, nil
+	merged.Source = strings.Join(sources, ", ")
+	merged.Version = version
+	return merged
 }
 
-// loadChargesMAC charge les charges avec MAC/véhicule
-func (db *DB) loadChargesMAC() ([]models.ChargeMAC, error) {
-	// Note: kpi_charges_mac contient seulement: ID, Site, MAC Address, Vehicle, Datetime start, is_ok, SOC Start, SOC End
-	query := "SELECT ID, Site, `MAC Address`, Vehicle, `Datetime start`, " +
-		"`SOC Start`, `SOC End`, is_ok " +
-		"FROM kpi_charges_mac"
+// LastReport retourne le rapport du dernier refresh (nil avant le premier)
+func (db *DB) LastReport() *RefreshReport {
+	return db.report.Load()
+}
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
+func (db *DB) lastReportValue() RefreshReport {
+	if r := db.LastReport(); r != nil {
+		return *r
 	}
-	defer rows.Close()
-
-	var charges []models.ChargeMAC
-	for rows.Next() {
-		var c models.ChargeMAC
-		var isOKInt int
-		err := rows.Scan(&c.ID, &c.Site, &c.MACAddress, &c.Vehicle,
-			&c.DatetimeStart, &c.SOCStart, &c.SOCEnd, &isOKInt)
-		if err != nil {
-			continue
-		}
-		c.IsOK = isOKInt == 1
-		charges = append(charges, c)
-	}
-
-	return charges, nil
+	return RefreshReport{}
 }
 
-// loadStatsGlobal charge les stats globales d'évolution
-func (db *DB) loadStatsGlobal() ([]models.StatsGlobal, error) {
-	query := `SELECT mois, tr FROM kpi_evo ORDER BY mois`
-
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// Version retourne le numéro de version de l'instantané (0 = jamais chargé)
+func (c *Cache) Version() uint64 {
+	return c.version
+}
 
-	var stats []models.StatsGlobal
-	for rows.Next() {
-		var s models.StatsGlobal
-		err := rows.Scan(&s.Mois, &s.TauxReussite)
-		if err != nil {
-			continue
-		}
-		stats = append(stats, s)
-	}
+// LastUpdate retourne la date de construction de l'instantané
+func (c *Cache) LastUpdate() time.Time {
+	return c.lastUpdate
+}
 
-	return stats, nil
+// RestoredAt retourne la date de la sauvegarde disque dont provient
+// l'instantané, ou zéro s'il vient d'un refresh
+func (c *Cache) RestoredAt() time.Time {
+	return c.restoredAt
 }
 
-// loadChargesDaily charge les charges quotidiennes
-func (db *DB) loadChargesDaily() ([]models.ChargesDaily, error) {
-	query := `SELECT Site, day, Status, Nb FROM kpi_charges_daily_by_site`
+// Sessions retourne les sessions de l'instantané, triées par date de début
+func (c *Cache) Sessions() columnar.View {
+	return c.sessions.All()
+}
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// Footprint estime la mémoire occupée par les sessions de l'instantané
+func (c *Cache) Footprint() columnar.Footprint {
+	return c.sessions.Footprint()
+}
 
-	var charges []models.ChargesDaily
-	for rows.Next() {
-		var c models.ChargesDaily
-		err := rows.Scan(&c.Site, &c.Day, &c.Status, &c.Nb)
-		if err != nil {
-			continue
-		}
-		charges = append(charges, c)
-	}
+// SessionIndex retourne l'index des sessions de l'instantané (nil avant le
+// premier chargement ; ses méthodes acceptent un index nil)
+func (c *Cache) SessionIndex() *store.Sessions {
+	return c.sessionIndex
+}
 
-	return charges, nil
+// Rollup retourne le cube pré-agrégé de l'instantané (nil avant le premier
+// chargement)
+func (c *Cache) Rollup() *rollup.Cube {
+	return c.cube
 }
 
-// loadDurationsSiteDaily charge les durées par site
-func (db *DB) loadDurationsSiteDaily() ([]models.DurationsSiteDaily, error) {
-	query := `SELECT Site, day, dur_min FROM kpi_durations_site_daily`
+// FilterSessions retourne les sessions qui passent les filtres. Le
+// résultat est mémorisé et partagé : il ne doit pas être modifié.
+func (c *Cache) FilterSessions(ctx context.Context, filters models.Filters) (columnar.View, error) {
+	return Memoize(c, "sessions", filters, func() (columnar.View, error) {
+		return c.sessionIndex.Filter(ctx, filters)
+	})
+}
 
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// Cells retourne les sessions filtrées sous forme agrégée : lues dans le
+// cube quand la période tombe sur des jours entiers, sinon agrégées depuis
+// les sessions filtrées. Le résultat est mémorisé et partagé.
+func (c *Cache) Cells(ctx context.Context, filters models.Filters) ([]rollup.Cell, error) {
+	return Memoize(c, "cells", filters, func() ([]rollup.Cell, error) {
+		if cells, ok := c.cube.Select(filters); ok {
+			return cells, nil
+		}
 
-	var durations []models.DurationsSiteDaily
-	for rows.Next() {
-		var d models.DurationsSiteDaily
-		err := rows.Scan(&d.Site, &d.Day, &d.DurMin)
+		sessions, err := c.FilterSessions(ctx, filters)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		durations = append(durations, d)
-	}
-
-	return durations, nil
+		return rollup.Aggregate(sessions), nil
+	})
 }
 
-// loadDurationsPDCDaily charge les durées par PDC
-func (db *DB) loadDurationsPDCDaily() ([]models.DurationsPDCDaily, error) {
-	query := `SELECT Site, PDC, day, dur_min FROM kpi_durations_pdc_daily`
-
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
+// Memoize retourne le résultat kind mémorisé pour l'instantané et les
+// filtres, ou le calcule puis le mémorise. Les erreurs (requête annulée)
+// ne sont pas mémorisées.
+func Memoize[T any](c *Cache, kind string, filters models.Filters, calc func() (T, error)) (T, error) {
+	key := memo.Key(c.version, kind, filters)
+	if v, ok := c.memo.Get(key); ok {
+		return v.(T), nil
 	}
-	defer rows.Close()
 
-	var durations []models.DurationsPDCDaily
-	for rows.Next() {
-		var d models.DurationsPDCDaily
-		err := rows.Scan(&d.Site, &d.PDC, &d.Day, &d.DurMin)
-		if err != nil {
-			continue
-		}
-		durations = append(durations, d)
+	v, err := calc()
+	if err != nil {
+		return v, err
 	}
-
-	return durations, nil
+	c.memo.Add(key, v)
+	return v, nil
 }
 
-// GetSessions retourne les sessions du cache
-func (db *DB) GetSessions() []models.Session {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.sessions
+// MemoStats retourne l'activité du LRU des résultats filtrés
+func (db *DB) MemoStats() memo.Stats {
+	return db.memo.Stats()
 }
 
-// GetAlertes retourne les alertes du cache
-func (db *DB) GetAlertes() []models.Alerte {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.alertes
+// Alertes retourne les alertes de l'instantané
+func (c *Cache) Alertes() []models.Alerte {
+	return c.alertes
 }
 
-// GetDefauts retourne les défauts du cache
-func (db *DB) GetDefauts() []models.Defaut {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.defauts
+// Defauts retourne les défauts de l'instantané
+func (c *Cache) Defauts() []models.Defaut {
+	return c.defauts
 }
 
-// GetSuspicious retourne les transactions suspectes du cache
-func (db *DB) GetSuspicious() []models.SuspiciousTransaction {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.suspicious
+// Suspicious retourne les transactions suspectes de l'instantané
+func (c *Cache) Suspicious() []models.SuspiciousTransaction {
+	return c.suspicious
 }
 
-// GetMultiAttempts retourne les tentatives multiples du cache
-func (db *DB) GetMultiAttempts() []models.MultiAttempt {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.multiAttempts
+// MultiAttempts retourne les tentatives multiples de l'instantané
+func (c *Cache) MultiAttempts() []models.MultiAttempt {
+	return c.multiAttempts
 }
 
-// GetChargesMAC retourne les charges avec MAC/véhicule du cache
-func (db *DB) GetChargesMAC() []models.ChargeMAC {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.chargesMAC
+// ChargesMAC retourne les charges avec MAC/véhicule de l'instantané
+func (c *Cache) ChargesMAC() []models.ChargeMAC {
+	return c.chargesMAC
 }
 
-// GetStatsGlobal retourne les stats globales du cache
-func (db *DB) GetStatsGlobal() []models.StatsGlobal {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.statsGlobal
+// StatsGlobal retourne les stats globales de l'instantané
+func (c *Cache) StatsGlobal() []models.StatsGlobal {
+	return c.statsGlobal
 }
 
-// GetChargesDaily retourne les charges quotidiennes du cache
-func (db *DB) GetChargesDaily() []models.ChargesDaily {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.chargesDaily
+// ChargesDaily retourne les charges quotidiennes de l'instantané
+func (c *Cache) ChargesDaily() []models.ChargesDaily {
+	return c.chargesDaily
 }
 
-// GetDurationsSiteDaily retourne les durées par site du cache
-func (db *DB) GetDurationsSiteDaily() []models.DurationsSiteDaily {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.durationsSiteDaily
+// DurationsSiteDaily retourne les durées par site de l'instantané
+func (c *Cache) DurationsSiteDaily() []models.DurationsSiteDaily {
+	return c.durationsSiteDaily
 }
 
-// GetDurationsPDCDaily retourne les durées par PDC du cache
-func (db *DB) GetDurationsPDCDaily() []models.DurationsPDCDaily {
-	db.cache.mu.RLock()
-	defer db.cache.mu.RUnlock()
-	return db.cache.durationsPDCDaily
+// DurationsPDCDaily retourne les durées par PDC de l'instantané
+func (c *Cache) DurationsPDCDaily() []models.DurationsPDCDaily {
+	return c.durationsPDCDaily
 }
 
-// Close ferme la connexion à la base de données
+// Close attend la fin des refresh en cours (annulés au préalable par le
+// contexte de l'appelant) puis ferme les sources de données qui le permettent
 func (db *DB) Close() error {
-	if db.conn != nil {
-		return db.conn.Close()
+	var errs []error
+	for _, m := range db.members {
+		if err := m.close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
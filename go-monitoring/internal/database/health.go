@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// ErrNotConnected est retourné par RefreshCache tant que la source de
+// données n'est pas encore joignable
+var ErrNotConnected = errors.New("data source not connected")
+
+// ErrConnectionLost est retourné par un refresh qui a perdu la connexion
+// MySQL : la base repasse par connectLoop jusqu'au retour du ping
+var ErrConnectionLost = errors.New("data source connection lost")
+
+// State est l'état de santé du tableau de bord
+type State string
+
+const (
+	// StateConnecting : aucune donnée à servir, connexion ou premier
+	// chargement en cours
+	StateConnecting State = "connecting"
+	// StateHealthy : source joignable et dernier refresh complet
+	StateHealthy State = "healthy"
+	// StateDegraded : source injoignable ou tables en échec, mais les
+	// données servies restent récentes
+	StateDegraded State = "degraded"
+	// StateStale : une table n'a pas été rechargée depuis plus de deux
+	// intervalles de refresh
+	StateStale State = "stale"
+)
+
+// Health décrit l'état exposé à l'UI et aux health checks. Avec plusieurs
+// bases fédérées, Sources détaille l'état de chacune et les champs de
+// premier niveau les résument.
+type Health struct {
+	State     State     `json:"state"`
+	Connected bool      `json:"connected"`
+	Attempts  int       `json:"connect_attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	NextRetry time.Time `json:"next_retry,omitempty"`
+	// OldestSuccess est le plus ancien dernier succès parmi les tables
+	OldestSuccess time.Time `json:"oldest_success"`
+
+	Sources []SourceHealth `json:"sources,omitempty"`
+}
+
+// SourceHealth décrit l'état d'une base fédérée
+type SourceHealth struct {
+	Name          string    `json:"name"`
+	State         State     `json:"state"`
+	Connected     bool      `json:"connected"`
+	Attempts      int       `json:"connect_attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextRetry     time.Time `json:"next_retry,omitempty"`
+	OldestSuccess time.Time `json:"oldest_success"`
+}
+
+// connState suit les tentatives de connexion (protégé par member.connMu)
+type connState struct {
+	connected bool
+	attempts  int
+	lastErr   string
+	nextRetry time.Time
+}
+
+// Health calcule l'état courant à partir des connexions, des instantanés
+// publiés et des derniers rapports de refresh
+func (db *DB) Health() Health {
+	staleAfter := db.staleAfter()
+	sources := make([]SourceHealth, len(db.members))
+	for i, m := range db.members {
+		sources[i] = m.health(staleAfter)
+	}
+
+	if len(sources) == 1 {
+		s := sources[0]
+		return Health{
+			State:         s.State,
+			Connected:     s.Connected,
+			Attempts:      s.Attempts,
+			LastError:     s.LastError,
+			NextRetry:     s.NextRetry,
+			OldestSuccess: s.OldestSuccess,
+		}
+	}
+
+	h := Health{Connected: true, Sources: sources}
+	counts := make(map[State]int)
+	for _, s := range sources {
+		counts[s.State]++
+		h.Attempts += s.Attempts
+		if !s.Connected {
+			h.Connected = false
+			if h.LastError == "" && s.LastError != "" {
+				h.LastError = s.Name + ": " + s.LastError
+			}
+			if !s.NextRetry.IsZero() && (h.NextRetry.IsZero() || s.NextRetry.Before(h.NextRetry)) {
+				h.NextRetry = s.NextRetry
+			}
+		}
+	}
+	if report := db.LastReport(); report != nil {
+		h.OldestSuccess = report.OldestSuccess()
+	}
+
+	// Une base sans données ne rend le tout indisponible que si aucune
+	// autre n'en sert ; une base périmée rend le tout périmé
+	switch {
+	case counts[StateConnecting] == len(sources):
+		h.State = StateConnecting
+	case counts[StateStale] > 0:
+		h.State = StateStale
+	case counts[StateHealthy] == len(sources):
+		h.State = StateHealthy
+	default:
+		h.State = StateDegraded
+	}
+
+	return h
+}
+
+// health calcule l'état d'une base
+func (m *member) health(staleAfter time.Duration) SourceHealth {
+	m.connMu.Lock()
+	h := SourceHealth{
+		Name:      m.name,
+		Connected: m.conn.connected,
+		Attempts:  m.conn.attempts,
+		LastError: m.conn.lastErr,
+		NextRetry: m.conn.nextRetry,
+	}
+	m.connMu.Unlock()
+
+	report := m.report.Load()
+	if report != nil {
+		h.OldestSuccess = report.OldestSuccess()
+	}
+
+	switch {
+	case m.cache.Load().Version() == 0:
+		h.State = StateConnecting
+	case h.OldestSuccess.IsZero() || time.Since(h.OldestSuccess) > staleAfter:
+		h.State = StateStale
+	case !h.Connected || len(report.Failed()) > 0:
+		h.State = StateDegraded
+	default:
+		h.State = StateHealthy
+	}
+
+	return h
+}
+
+// staleAfter : le cache est considéré périmé après deux refresh manqués
+func (db *DB) staleAfter() time.Duration {
+	return 2 * db.cacheCfg.RefreshInterval.D()
+}
+
+// connectLoop tente de connecter une base jusqu'au succès, avec une attente
+// doublée à chaque échec, puis lance son premier refresh. Les données
+// restaurées ou déjà chargées restent servies entre-temps.
+func (db *DB) connectLoop(ctx context.Context, m *member) {
+	backoff := m.cfg.ConnectRetryMin.D()
+
+	for {
+		err := m.connect(ctx)
+		if err == nil {
+			db.refreshMember(ctx, m)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait := withJitter(backoff)
+		m.connMu.Lock()
+		m.conn.attempts++
+		m.conn.lastErr = err.Error()
+		m.conn.nextRetry = time.Now().Add(wait)
+		attempts := m.conn.attempts
+		m.connMu.Unlock()
+
+		log.Printf("⚠️ %s unreachable (attempt %d), retrying in %s: %v", m.label(), attempts, wait.Round(time.Second), err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		backoff = min(2*backoff, m.cfg.ConnectRetryMax.D())
+	}
+}
+
+// setConnected marque la base comme joignable
+func (m *member) setConnected() {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+
+	m.conn = connState{connected: true, attempts: m.conn.attempts}
+}
+
+// setDisconnected marque la base comme injoignable après une perte de
+// connexion ; connectLoop reprend ensuite les tentatives
+func (m *member) setDisconnected(err error) {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+
+	m.conn.connected = false
+	m.conn.lastErr = err.Error()
+	m.conn.nextRetry = time.Time{}
+}
+
+// withJitter étale les tentatives entre d/2 et d pour éviter que plusieurs
+// instances redémarrées ensemble ne reviennent en même temps
+func withJitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
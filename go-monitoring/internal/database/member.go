@@ -0,0 +1,378 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/config"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// member est une base KPI fédérée : sa connexion, son dernier instantané et
+// son rapport de refresh lui sont propres, pour qu'une base en panne ne
+// bloque pas les autres
+type member struct {
+	name string
+	cfg  config.DatabaseConfig
+
+	// source est nil tant que la connexion n'est pas établie (protégé par
+	// refreshMu)
+	source DataSource
+	// mysql est la connexion MySQL derrière source, nil pour une source
+	// fournie à la création (protégé par refreshMu)
+	mysql *MySQLSource
+
+	// cache est le dernier instantané de cette base seule
+	cache  atomic.Pointer[Cache]
+	report atomic.Pointer[RefreshReport]
+
+	// refreshMu sérialise les refresh de la base
+	refreshMu sync.Mutex
+	// lastFullSessions date le dernier rechargement complet des sessions
+	// (protégé par refreshMu)
+	lastFullSessions time.Time
+
+	connMu sync.Mutex
+	conn   connState
+}
+
+func newMember(name string, source DataSource, cfg config.DatabaseConfig) *member {
+	m := &member{name: name, cfg: cfg}
+	if source != nil {
+		m.source = tagSource(name, source)
+		m.conn.connected = true
+	}
+	m.cache.Store(&Cache{})
+	return m
+}
+
+// label identifie la base dans les logs
+func (m *member) label() string {
+	if m.name == "" {
+		return "database"
+	}
+	return "database " + m.name
+}
+
+// connect établit la connexion à MySQL
+func (m *member) connect(ctx context.Context) error {
+	source, err := OpenMySQL(ctx, m.cfg)
+	if err != nil {
+		return err
+	}
+
+	m.refreshMu.Lock()
+	m.source = tagSource(m.name, source)
+	m.mysql = source
+	m.refreshMu.Unlock()
+	m.setConnected()
+	log.Printf("✅ Connected to MySQL %s", m.label())
+
+	return nil
+}
+
+// refresh construit un nouvel instantané de la base à l'écart puis le
+// publie atomiquement. Une table en erreur conserve les données de
+// l'instantané précédent ; l'erreur retournée liste les tables en échec. Si
+// ctx est annulé en cours de route, rien n'est publié. Si MySQL ne répond
+// plus au ping après un échec, la connexion est abandonnée et l'erreur
+// enveloppe ErrConnectionLost.
+func (m *member) refresh(ctx context.Context, cacheCfg config.CacheConfig) (RefreshReport, error) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	if m.source == nil {
+		return RefreshReport{}, ErrNotConnected
+	}
+
+	log.Printf("🔄 Refreshing %s from %s...", m.label(), m.source.Name())
+
+	prev := m.cache.Load()
+	next := *prev
+	next.restoredAt = time.Time{}
+
+	report := RefreshReport{
+		Source:    m.source.Name(),
+		StartedAt: time.Now(),
+	}
+	prevReport := m.report.Load()
+	since, incremental := m.sessionsWatermark(prev, cacheCfg)
+
+	// Les tables se chargent en parallèle, dans la limite du pool de
+	// connexions ; chaque chargeur ne remplit que son champ de next, publié
+	// une fois toutes les tables chargées
+	loaders := m.tableLoaders(since, incremental)
+	report.Tables = make([]TableReport, len(loaders))
+	sem := make(chan struct{}, m.loadConcurrency())
+	var wg sync.WaitGroup
+	for i, t := range loaders {
+		var prevTable *TableReport
+		if prevReport != nil && i < len(prevReport.Tables) {
+			prevTable = &prevReport.Tables[i]
+		}
+
+		wg.Add(1)
+		go func(i int, t tableLoader) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			report.Tables[i] = m.loadTable(ctx, t, &next, prevTable)
+		}(i, t)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		log.Printf("🛑 Cache refresh cancelled: %v", err)
+		return report, err
+	}
+
+	// Le rechargement complet ne compte qu'une fois publié
+	for i, t := range loaders {
+		if t.table == TableSessions && t.mode == ModeFull && report.Tables[i].Error == "" {
+			m.lastFullSessions = report.Tables[i].LastSuccess
+		}
+	}
+
+	next.version = prev.version + 1
+	next.lastUpdate = time.Now()
+	m.cache.Store(&next)
+
+	report.Version = next.version
+	report.DurationMs = msSince(report.StartedAt)
+	m.report.Store(&report)
+
+	if err := report.Err(); err != nil {
+		log.Printf("⚠️ Cache refreshed with errors (%s, version %d): %v", m.label(), next.version, err)
+		if lost := m.checkConnection(ctx); lost != nil {
+			return report, fmt.Errorf("%w: %v", ErrConnectionLost, lost)
+		}
+		return report, err
+	}
+
+	log.Printf("✅ Cache refreshed successfully (%s, version %d)", m.label(), next.version)
+	return report, nil
+}
+
+// checkConnection vérifie par un ping que MySQL répond encore après des
+// tables en échec. Sinon la connexion est fermée et la base marquée
+// injoignable : les refresh suivants retournent ErrNotConnected jusqu'à la
+// reconnexion. Appelé sous refreshMu.
+func (m *member) checkConnection(ctx context.Context) error {
+	if m.mysql == nil {
+		return nil
+	}
+	err := m.mysql.Ping(ctx)
+	if err == nil || ctx.Err() != nil {
+		return nil
+	}
+
+	log.Printf("🔌 Lost connection to %s: %v", m.label(), err)
+	m.mysql.Close()
+	m.source = nil
+	m.mysql = nil
+	m.setDisconnected(err)
+	return err
+}
+
+// loadTable charge une table dans next et retourne son rapport ; en cas
+// d'erreur, la date du dernier succès est reprise du rapport précédent
+func (m *member) loadTable(ctx context.Context, t tableLoader, next *Cache, prev *TableReport) TableReport {
+	rej := &Rejects{Table: t.table}
+	start := time.Now()
+	rows, err := t.load(ctx, rej, next)
+
+	tr := TableReport{
+		Source:     m.name,
+		Table:      t.table,
+		Mode:       t.mode,
+		Rows:       rows,
+		Rejected:   rej.Count,
+		DurationMs: msSince(start),
+	}
+	for _, sample := range rej.Samples {
+		tr.Samples = append(tr.Samples, sample.Error())
+	}
+
+	if err != nil {
+		log.Printf("Error loading %s: %v", t.table, err)
+		tr.Error = err.Error()
+		if prev != nil {
+			tr.LastSuccess = prev.LastSuccess
+		}
+	} else {
+		tr.LastSuccess = time.Now()
+	}
+	if rej.Count > 0 {
+		log.Printf("⚠️ %s: %d row(s) rejected (first: %v)", t.table, rej.Count, rej.Samples[0])
+	}
+
+	return tr
+}
+
+// loadConcurrency borne le nombre de tables chargées en même temps : pas
+// plus que de connexions dans le pool
+func (m *member) loadConcurrency() int {
+	n := m.cfg.LoadConcurrency
+	if n > m.cfg.MaxOpenConns && m.cfg.MaxOpenConns > 0 {
+		n = m.cfg.MaxOpenConns
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Modes de chargement d'une table
+const (
+	ModeFull        = "full"
+	ModeIncremental = "incremental"
+)
+
+// tableLoader charge une table dans l'instantané en construction. Les
+// chargeurs d'un même refresh tournent en parallèle : chacun ne touche que
+// le champ de sa table.
+type tableLoader struct {
+	table string
+	mode  string
+	load  func(ctx context.Context, rej *Rejects, next *Cache) (int, error)
+}
+
+// tableLoaders liste les tables du cache dans un ordre stable
+func (m *member) tableLoaders(since time.Time, incremental bool) []tableLoader {
+	src := m.source
+	sessions := tableLoader{TableSessions, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+		rows, err := src.LoadSessions(ctx, rej)
+		if err != nil {
+			return 0, err
+		}
+		c.sessions = columnar.FromRows(rows)
+		return len(rows), nil
+	}}
+	if inc, ok := src.(IncrementalSessionSource); ok && incremental {
+		sessions = tableLoader{TableSessions, ModeIncremental, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			delta, err := inc.LoadSessionsSince(ctx, rej, since)
+			if err != nil {
+				return 0, err
+			}
+			log.Printf("🔁 Incremental sessions refresh: %d row(s) since %s", len(delta), since.Format("2006-01-02 15:04:05"))
+			c.sessions = mergeSessions(c.sessions, delta, since)
+			return c.sessions.Len(), nil
+		}}
+	}
+
+	return []tableLoader{
+		sessions,
+		{TableAlertes, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.alertes)(src.LoadAlertes(ctx, rej))
+		}},
+		{TableDefauts, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.defauts)(src.LoadDefauts(ctx, rej))
+		}},
+		{TableSuspicious, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.suspicious)(src.LoadSuspicious(ctx, rej))
+		}},
+		{TableMultiAttempts, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.multiAttempts)(src.LoadMultiAttempts(ctx, rej))
+		}},
+		{TableChargesMAC, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.chargesMAC)(src.LoadChargesMAC(ctx, rej))
+		}},
+		{TableEvo, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.statsGlobal)(src.LoadStatsGlobal(ctx, rej))
+		}},
+		{TableChargesDaily, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.chargesDaily)(src.LoadChargesDaily(ctx, rej))
+		}},
+		{TableDurationsSiteDaily, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.durationsSiteDaily)(src.LoadDurationsSiteDaily(ctx, rej))
+		}},
+		{TableDurationsPDCDaily, ModeFull, func(ctx context.Context, rej *Rejects, c *Cache) (int, error) {
+			return into(&c.durationsPDCDaily)(src.LoadDurationsPDCDaily(ctx, rej))
+		}},
+	}
+}
+
+// into remplace *dst par les lignes chargées, sauf en cas d'erreur
+func into[T any](dst *[]T) func([]T, error) (int, error) {
+	return func(rows []T, err error) (int, error) {
+		if err != nil {
+			return 0, err
+		}
+		*dst = rows
+		return len(rows), nil
+	}
+}
+
+// sessionsWatermark détermine si les sessions peuvent être rechargées de
+// façon incrémentale et à partir de quelle date
+func (m *member) sessionsWatermark(prev *Cache, cfg config.CacheConfig) (time.Time, bool) {
+	if _, ok := m.source.(IncrementalSessionSource); !ok {
+		return time.Time{}, false
+	}
+
+	full := cfg.FullRefreshInterval.D()
+	if full <= 0 || m.lastFullSessions.IsZero() || time.Since(m.lastFullSessions) >= full {
+		return time.Time{}, false
+	}
+
+	// Les sessions sont triées : la dernière est la plus récente
+	n := prev.sessions.Len()
+	if n == 0 {
+		return time.Time{}, false
+	}
+	latest := prev.sessions.Row(n - 1).DatetimeStart()
+	if latest.IsZero() {
+		return time.Time{}, false
+	}
+
+	// Relire une fenêtre avant la dernière session connue pour récupérer
+	// les sessions encore en cours ou corrigées depuis le dernier refresh
+	return latest.Add(-cfg.IncrementalLookback.D()), true
+}
+
+// mergeSessions remplace dans prev les sessions relues depuis since.
+// Les sessions de prev démarrées après since et absentes du delta ont été
+// supprimées en base ; celles présentes dans le delta (même ID) sont
+// remplacées. prev n'est jamais modifié : il appartient à l'instantané
+// précédent.
+func mergeSessions(prev *columnar.Sessions, delta []models.Session, since time.Time) *columnar.Sessions {
+	fresh := make(map[string]bool, len(delta))
+	for _, s := range delta {
+		fresh[s.ID] = true
+	}
+
+	b := columnar.NewBuilder(prev.Len() + len(delta))
+	for it := prev.All().Iter(); it.Next(); {
+		s := it.Row()
+		if !s.DatetimeStart().Before(since) || fresh[s.ID()] {
+			continue
+		}
+		b.AppendRow(s)
+	}
+	for _, s := range delta {
+		b.Append(s)
+	}
+
+	return b.Build()
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
+
+// close attend la fin du refresh en cours puis ferme la source
+func (m *member) close() error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	if closer, ok := m.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/config"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// incrementalSource est une source CSV qui se déclare incrémentale
+type incrementalSource struct {
+	*CSVSource
+}
+
+func (incrementalSource) LoadSessionsSince(context.Context, *Rejects, time.Time) ([]models.Session, error) {
+	return nil, nil
+}
+
+func at(day, hour, min int) time.Time {
+	return time.Date(2024, time.March, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestSessionsWatermark(t *testing.T) {
+	prev := &Cache{sessions: columnar.FromRows([]models.Session{
+		{ID: "a", DatetimeStart: at(1, 8, 0)},
+		{ID: "b", DatetimeStart: at(1, 12, 0)},
+	})}
+	cfg := config.CacheConfig{
+		FullRefreshInterval: config.Duration(24 * time.Hour),
+		IncrementalLookback: config.Duration(6 * time.Hour),
+	}
+
+	tests := []struct {
+		name     string
+		source   DataSource
+		lastFull time.Time
+		cfg      config.CacheConfig
+		prev     *Cache
+		want     time.Time
+		wantInc  bool
+	}{
+		{
+			name:     "incrémental",
+			source:   incrementalSource{NewCSVSource("")},
+			lastFull: time.Now().Add(-time.Hour),
+			cfg:      cfg,
+			prev:     prev,
+			want:     at(1, 6, 0),
+			wantInc:  true,
+		},
+		{
+			name:     "source non incrémentale",
+			source:   NewCSVSource(""),
+			lastFull: time.Now().Add(-time.Hour),
+			cfg:      cfg,
+			prev:     prev,
+		},
+		{
+			name:   "jamais rechargé complètement",
+			source: incrementalSource{NewCSVSource("")},
+			cfg:    cfg,
+			prev:   prev,
+		},
+		{
+			name:     "rechargement complet dû",
+			source:   incrementalSource{NewCSVSource("")},
+			lastFull: time.Now().Add(-25 * time.Hour),
+			cfg:      cfg,
+			prev:     prev,
+		},
+		{
+			name:     "incrémental désactivé",
+			source:   incrementalSource{NewCSVSource("")},
+			lastFull: time.Now().Add(-time.Hour),
+			cfg:      config.CacheConfig{IncrementalLookback: cfg.IncrementalLookback},
+			prev:     prev,
+		},
+		{
+			name:     "aucune session",
+			source:   incrementalSource{NewCSVSource("")},
+			lastFull: time.Now().Add(-time.Hour),
+			cfg:      cfg,
+			prev:     &Cache{sessions: columnar.FromRows(nil)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &member{source: tt.source, lastFullSessions: tt.lastFull}
+			got, inc := m.sessionsWatermark(tt.prev, tt.cfg)
+			if inc != tt.wantInc || !got.Equal(tt.want) {
+				t.Errorf("sessionsWatermark = %v, %v, want %v, %v", got, inc, tt.want, tt.wantInc)
+			}
+		})
+	}
+}
+
+func TestMergeSessions(t *testing.T) {
+	prev := columnar.FromRows([]models.Session{
+		{ID: "undated", Site: "A"},
+		{ID: "old", DatetimeStart: at(1, 2, 0), Site: "A"},
+		// Avant la fenêtre mais corrigée depuis : remplacée par le delta
+		{ID: "moved", DatetimeStart: at(1, 5, 0), Site: "A"},
+		// Dans la fenêtre et absente du delta : supprimée en base
+		{ID: "deleted", DatetimeStart: at(1, 7, 0), Site: "A"},
+		// Dans la fenêtre, relue avec son état final
+		{ID: "running", DatetimeStart: at(1, 11, 0), Site: "A", StateOfCharge: 1},
+		{ID: "latest", DatetimeStart: at(1, 12, 0), Site: "A"},
+	})
+	since := at(1, 6, 0) // dernière session - 6h de lookback
+	delta := []models.Session{
+		{ID: "moved", DatetimeStart: at(1, 6, 30), Site: "B"},
+		{ID: "running", DatetimeStart: at(1, 11, 0), Site: "A"},
+		{ID: "latest", DatetimeStart: at(1, 12, 0), Site: "A"},
+		{ID: "new", DatetimeStart: at(1, 13, 0), Site: "A"},
+	}
+
+	got := mergeSessions(prev, delta, since)
+
+	want := map[string]models.Session{
+		"undated": {ID: "undated", Site: "A"},
+		"old":     {ID: "old", DatetimeStart: at(1, 2, 0), Site: "A"},
+		"moved":   {ID: "moved", DatetimeStart: at(1, 6, 30), Site: "B"},
+		"running": {ID: "running", DatetimeStart: at(1, 11, 0), Site: "A"},
+		"latest":  {ID: "latest", DatetimeStart: at(1, 12, 0), Site: "A"},
+		"new":     {ID: "new", DatetimeStart: at(1, 13, 0), Site: "A"},
+	}
+	if got.Len() != len(want) {
+		t.Errorf("merged %d sessions, want %d", got.Len(), len(want))
+	}
+
+	seen := make(map[string]bool)
+	var starts []int64
+	for i := 0; i < got.Len(); i++ {
+		s := got.Row(i).Session()
+		if seen[s.ID] {
+			t.Errorf("session %s duplicated", s.ID)
+		}
+		seen[s.ID] = true
+
+		w, ok := want[s.ID]
+		if !ok {
+			t.Errorf("unexpected session %s", s.ID)
+			continue
+		}
+		if !s.DatetimeStart.Equal(w.DatetimeStart) || s.Site != w.Site || s.StateOfCharge != w.StateOfCharge {
+			t.Errorf("session %s = %v %s state %d, want %v %s state %d", s.ID, s.DatetimeStart, s.Site, s.StateOfCharge, w.DatetimeStart, w.Site, w.StateOfCharge)
+		}
+		if !s.DatetimeStart.IsZero() {
+			starts = append(starts, s.DatetimeStart.UnixNano())
+		}
+	}
+	if !sort.SliceIsSorted(starts, func(i, j int) bool { return starts[i] < starts[j] }) {
+		t.Errorf("merged sessions are not sorted by start")
+	}
+
+	// L'instantané précédent est intact
+	if prev.Len() != 6 || prev.Row(4).StateOfCharge() != 1 {
+		t.Errorf("prev modified: %d sessions", prev.Len())
+	}
+}
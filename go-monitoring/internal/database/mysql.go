@@ -0,0 +1,414 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/monitoring/charging-stations/internal/config"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// MySQLSource lit les tables kpi_* depuis la base MySQL Charges
+type MySQLSource struct {
+	conn         *sql.DB
+	queryTimeout time.Duration
+}
+
+// OpenMySQL ouvre et vérifie la connexion MySQL. parseTime est forcé : les
+// colonnes DATETIME sont lues dans des time.Time
+func OpenMySQL(ctx context.Context, cfg config.DatabaseConfig) (*MySQLSource, error) {
+	dsn, err := mysql.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing database DSN: %w", err)
+	}
+	dsn.ParseTime = true
+
+	conn, err := sql.Open("mysql", dsn.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	// Configuration de la connexion
+	conn.SetMaxOpenConns(cfg.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.ConnMaxLifetime.D())
+
+	// Test de connexion
+	m := &MySQLSource{conn: conn, queryTimeout: cfg.QueryTimeout.D()}
+
+	if err := m.Ping(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error pinging database: %w", err)
+	}
+
+	return m, nil
+}
+
+// Ping vérifie que MySQL répond encore
+func (m *MySQLSource) Ping(ctx context.Context) error {
+	pingCtx, cancel := m.withTimeout(ctx)
+	defer cancel()
+	return m.conn.PingContext(pingCtx)
+}
+
+// withTimeout borne la durée d'une requête ; une requête bloquée ne peut
+// plus geler le refresh
+func (m *MySQLSource) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.queryTimeout)
+}
+
+// Name implémente DataSource
+func (m *MySQLSource) Name() string {
+	return "mysql"
+}
+
+// Close ferme la connexion MySQL
+func (m *MySQLSource) Close() error {
+	return m.conn.Close()
+}
+
+// sessionsQuery sélectionne les colonnes de kpi_sessions lues par le cache
+const sessionsQuery = "SELECT ID, `Datetime start`, `Datetime end`, COALESCE(Site, `Name Project`) as Site, " +
+	"PDC, `State of charge(0:good, 1:error)`, type_erreur, moment, moment_avancee, " +
+	"`EVI Error Code`, `EVI Status during error`, `Downstream Code PC`, `Energy (Kwh)`, " +
+	"`Mean Power (Kw)`, `Max Power (Kw)`, `SOC Start`, `SOC End`, `MAC Address`, charge_900V " +
+	"FROM kpi_sessions"
+
+// LoadSessions charge les sessions depuis la table kpi_sessions
+func (m *MySQLSource) LoadSessions(ctx context.Context, rej *Rejects) ([]models.Session, error) {
+	return m.querySessions(ctx, rej, sessionsQuery)
+}
+
+// LoadSessionsSince charge les sessions démarrées à partir de since
+func (m *MySQLSource) LoadSessionsSince(ctx context.Context, rej *Rejects, since time.Time) ([]models.Session, error) {
+	return m.querySessions(ctx, rej, sessionsQuery+" WHERE `Datetime start` >= ?", since)
+}
+
+func (m *MySQLSource) querySessions(ctx context.Context, rej *Rejects, query string, args ...interface{}) ([]models.Session, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	line := 0
+	for rows.Next() {
+		line++
+		var s models.Session
+		err := rows.Scan(
+			&s.ID,
+			&s.DatetimeStart,
+			&s.DatetimeEnd,
+			&s.Site,
+			&s.PDC,
+			&s.StateOfCharge,
+			&s.TypeErreur,
+			&s.Moment,
+			&s.MomentAvancee,
+			&s.EVIErrorCode,
+			&s.EVIMomentStep,
+			&s.DownstreamCodePC,
+			&s.EnergyKwh,
+			&s.MeanPowerKw,
+			&s.MaxPowerKw,
+			&s.SOCStart,
+			&s.SOCEnd,
+			&s.MACAddress,
+			&s.Charge900V,
+		)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// LoadAlertes charge les alertes
+func (m *MySQLSource) LoadAlertes(ctx context.Context, rej *Rejects) ([]models.Alerte, error) {
+	query := `SELECT Site, PDC, type_erreur, detection, occurrences_12h, moment, evi_code, downstream_code_pc
+		FROM kpi_alertes ORDER BY detection DESC`
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alertes []models.Alerte
+	line := 0
+	for rows.Next() {
+		line++
+		var a models.Alerte
+		err := rows.Scan(&a.Site, &a.PDC, &a.TypeErreur, &a.Detection, &a.Occurrences12h,
+			&a.Moment, &a.EVICode, &a.DownstreamCodePC)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		alertes = append(alertes, a)
+	}
+
+	return alertes, rows.Err()
+}
+
+// LoadDefauts charge les défauts
+func (m *MySQLSource) LoadDefauts(ctx context.Context, rej *Rejects) ([]models.Defaut, error) {
+	query := `SELECT site, date_debut, date_fin, defaut, eqp
+		FROM kpi_defauts_log ORDER BY date_debut DESC`
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defauts []models.Defaut
+	line := 0
+	for rows.Next() {
+		line++
+		var d models.Defaut
+		err := rows.Scan(&d.Site, &d.DateDebut, &d.DateFin, &d.Defaut, &d.Equipement)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		defauts = append(defauts, d)
+	}
+
+	return defauts, rows.Err()
+}
+
+// LoadSuspicious charge les transactions suspectes
+func (m *MySQLSource) LoadSuspicious(ctx context.Context, rej *Rejects) ([]models.SuspiciousTransaction, error) {
+	query := "SELECT ID, Site, PDC, `MAC Address`, Vehicle, `Datetime start`, `Datetime end`, " +
+		"`Energy (Kwh)`, `SOC Start`, `SOC End` FROM kpi_suspicious_under_1kwh"
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suspicious []models.SuspiciousTransaction
+	line := 0
+	for rows.Next() {
+		line++
+		var s models.SuspiciousTransaction
+		err := rows.Scan(&s.ID, &s.Site, &s.PDC, &s.MACAddress, &s.Vehicle,
+			&s.DatetimeStart, &s.DatetimeEnd, &s.EnergyKwh, &s.SOCStart, &s.SOCEnd)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		suspicious = append(suspicious, s)
+	}
+
+	return suspicious, rows.Err()
+}
+
+// LoadMultiAttempts charge les tentatives multiples
+func (m *MySQLSource) LoadMultiAttempts(ctx context.Context, rej *Rejects) ([]models.MultiAttempt, error) {
+	query := "SELECT Site, Heure, MAC, Vehicle, tentatives, `PDC(s)`, " +
+		"`1ère tentative`, `Dernière tentative`, `ID(s)`, " +
+		"`SOC start min`, `SOC start max`, `SOC end min`, `SOC end max` " +
+		"FROM kpi_multi_attempts_hour"
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []models.MultiAttempt
+	line := 0
+	for rows.Next() {
+		line++
+		var m models.MultiAttempt
+		err := rows.Scan(&m.Site, &m.Heure, &m.MAC, &m.Vehicle, &m.Tentatives, &m.PDCs,
+			&m.PremiereTentative, &m.DerniereTentative, &m.IDs,
+			&m.SOCStartMin, &m.SOCStartMax, &m.SOCEndMin, &m.SOCEndMax)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		attempts = append(attempts, m)
+	}
+
+	return attempts, rows.Err()
+}
+
+// LoadChargesMAC charge les charges avec MAC/véhicule
+func (m *MySQLSource) LoadChargesMAC(ctx context.Context, rej *Rejects) ([]models.ChargeMAC, error) {
+	// Note: kpi_charges_mac contient seulement: ID, Site, MAC Address, Vehicle, Datetime start, is_ok, SOC Start, SOC End
+	query := "SELECT ID, Site, `MAC Address`, Vehicle, `Datetime start`, " +
+		"`SOC Start`, `SOC End`, is_ok " +
+		"FROM kpi_charges_mac"
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var charges []models.ChargeMAC
+	line := 0
+	for rows.Next() {
+		line++
+		var c models.ChargeMAC
+		var isOKInt int
+		err := rows.Scan(&c.ID, &c.Site, &c.MACAddress, &c.Vehicle,
+			&c.DatetimeStart, &c.SOCStart, &c.SOCEnd, &isOKInt)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		c.IsOK = isOKInt == 1
+		charges = append(charges, c)
+	}
+
+	return charges, rows.Err()
+}
+
+// LoadStatsGlobal charge les stats globales d'évolution
+func (m *MySQLSource) LoadStatsGlobal(ctx context.Context, rej *Rejects) ([]models.StatsGlobal, error) {
+	query := `SELECT mois, tr FROM kpi_evo ORDER BY mois`
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.StatsGlobal
+	line := 0
+	for rows.Next() {
+		line++
+		var s models.StatsGlobal
+		err := rows.Scan(&s.Mois, &s.TauxReussite)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// LoadChargesDaily charge les charges quotidiennes
+func (m *MySQLSource) LoadChargesDaily(ctx context.Context, rej *Rejects) ([]models.ChargesDaily, error) {
+	query := `SELECT Site, day, Status, Nb FROM kpi_charges_daily_by_site`
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var charges []models.ChargesDaily
+	line := 0
+	for rows.Next() {
+		line++
+		var c models.ChargesDaily
+		err := rows.Scan(&c.Site, &c.Day, &c.Status, &c.Nb)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		charges = append(charges, c)
+	}
+
+	return charges, rows.Err()
+}
+
+// LoadDurationsSiteDaily charge les durées par site
+func (m *MySQLSource) LoadDurationsSiteDaily(ctx context.Context, rej *Rejects) ([]models.DurationsSiteDaily, error) {
+	query := `SELECT Site, day, dur_min FROM kpi_durations_site_daily`
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var durations []models.DurationsSiteDaily
+	line := 0
+	for rows.Next() {
+		line++
+		var d models.DurationsSiteDaily
+		err := rows.Scan(&d.Site, &d.Day, &d.DurMin)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		durations = append(durations, d)
+	}
+
+	return durations, rows.Err()
+}
+
+// LoadDurationsPDCDaily charge les durées par PDC
+func (m *MySQLSource) LoadDurationsPDCDaily(ctx context.Context, rej *Rejects) ([]models.DurationsPDCDaily, error) {
+	query := `SELECT Site, PDC, day, dur_min FROM kpi_durations_pdc_daily`
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := m.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var durations []models.DurationsPDCDaily
+	line := 0
+	for rows.Next() {
+		line++
+		var d models.DurationsPDCDaily
+		err := rows.Scan(&d.Site, &d.PDC, &d.Day, &d.DurMin)
+		if err != nil {
+			rej.Add(line, err)
+			continue
+		}
+		durations = append(durations, d)
+	}
+
+	return durations, rows.Err()
+}
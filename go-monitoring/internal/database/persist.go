@@ -0,0 +1,209 @@
+package database
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// snapshotFormat est incrémenté à chaque changement incompatible du format
+const snapshotFormat = 3
+
+// persistedCache est la forme sérialisée (gob + gzip) du cache : un
+// instantané par base fédérée, pour que chacune reparte de ses propres
+// données au démarrage
+type persistedCache struct {
+	Format  int
+	SavedAt time.Time
+	Sources []persistedSource
+}
+
+// persistedSource est l'instantané sauvegardé d'une base
+type persistedSource struct {
+	Name               string
+	Version            uint64
+	LastUpdate         time.Time
+	Report             *RefreshReport
+	Sessions           *columnar.Sessions
+	Alertes            []models.Alerte
+	Defauts            []models.Defaut
+	Suspicious         []models.SuspiciousTransaction
+	MultiAttempts      []models.MultiAttempt
+	ChargesMAC         []models.ChargeMAC
+	StatsGlobal        []models.StatsGlobal
+	ChargesDaily       []models.ChargesDaily
+	DurationsSiteDaily []models.DurationsSiteDaily
+	DurationsPDCDaily  []models.DurationsPDCDaily
+}
+
+func toPersisted(name string, c *Cache, report *RefreshReport) persistedSource {
+	return persistedSource{
+		Name:               name,
+		Version:            c.version,
+		LastUpdate:         c.lastUpdate,
+		Report:             report,
+		Sessions:           c.sessions,
+		Alertes:            c.alertes,
+		Defauts:            c.defauts,
+		Suspicious:         c.suspicious,
+		MultiAttempts:      c.multiAttempts,
+		ChargesMAC:         c.chargesMAC,
+		StatsGlobal:        c.statsGlobal,
+		ChargesDaily:       c.chargesDaily,
+		DurationsSiteDaily: c.durationsSiteDaily,
+		DurationsPDCDaily:  c.durationsPDCDaily,
+	}
+}
+
+func (p persistedSource) cache(savedAt time.Time) *Cache {
+	return &Cache{
+		version:            p.Version,
+		sessions:           p.Sessions,
+		alertes:            p.Alertes,
+		defauts:            p.Defauts,
+		suspicious:         p.Suspicious,
+		multiAttempts:      p.MultiAttempts,
+		chargesMAC:         p.ChargesMAC,
+		statsGlobal:        p.StatsGlobal,
+		chargesDaily:       p.ChargesDaily,
+		durationsSiteDaily: p.DurationsSiteDaily,
+		durationsPDCDaily:  p.DurationsPDCDaily,
+		lastUpdate:         p.LastUpdate,
+		restoredAt:         savedAt,
+	}
+}
+
+// saveSnapshot écrit le cache sur disque. L'écriture passe par un fichier
+// temporaire renommé ensuite, pour ne jamais laisser de fichier tronqué si
+// le processus s'arrête en cours d'écriture.
+func saveSnapshot(path string, p *persistedCache) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if err := gob.NewEncoder(zw).Encode(p); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadSnapshot relit un cache écrit par saveSnapshot
+func loadSnapshot(path string) (*persistedCache, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var p persistedCache
+	if err := gob.NewDecoder(zr).Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Format != snapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format %d (want %d)", p.Format, snapshotFormat)
+	}
+
+	return &p, nil
+}
+
+// restoreSnapshot publie les instantanés sauvegardés, s'ils existent, pour
+// servir des données dès le démarrage en attendant le premier refresh. Les
+// bases absentes de la configuration sont ignorées.
+func (db *DB) restoreSnapshot() {
+	path := db.cacheCfg.SnapshotPath
+	if path == "" {
+		return
+	}
+
+	p, err := loadSnapshot(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Ignoring cache snapshot %s: %v", path, err)
+		}
+		return
+	}
+
+	restored := 0
+	for _, ps := range p.Sources {
+		for _, m := range db.members {
+			if m.name != ps.Name {
+				continue
+			}
+			m.cache.Store(ps.cache(p.SavedAt))
+			if ps.Report != nil {
+				m.report.Store(ps.Report)
+			}
+			restored++
+			log.Printf("📦 Restored cache snapshot of %s (version %d, %d sessions)", m.label(), ps.Version, ps.Sessions.Len())
+		}
+	}
+	if restored == 0 {
+		return
+	}
+
+	db.publish()
+	log.Printf("📦 Cache snapshot %s saved %s restored", path, p.SavedAt.Format("2006-01-02 15:04:05"))
+}
+
+// persistSnapshot sauvegarde le dernier instantané de chaque base. Il est
+// appelé après un refresh complet d'une base ; les autres bases y figurent
+// avec leurs dernières données.
+func (db *DB) persistSnapshot() {
+	path := db.cacheCfg.SnapshotPath
+	if path == "" {
+		return
+	}
+
+	// Les instantanés sont relevés sous saveMu pour qu'une sauvegarde plus
+	// ancienne n'écrase jamais une plus récente ; publishMu n'est tenu que le
+	// temps de les relever, l'encodage et l'écriture se font hors verrou
+	db.saveMu.Lock()
+	defer db.saveMu.Unlock()
+
+	p := &persistedCache{Format: snapshotFormat, SavedAt: time.Now()}
+	db.publishMu.Lock()
+	for _, m := range db.members {
+		c := m.cache.Load()
+		if c.version == 0 {
+			continue
+		}
+		p.Sources = append(p.Sources, toPersisted(m.name, c, m.report.Load()))
+	}
+	db.publishMu.Unlock()
+
+	start := time.Now()
+	if err := saveSnapshot(path, p); err != nil {
+		log.Printf("⚠️ Error saving cache snapshot: %v", err)
+		return
+	}
+	log.Printf("💾 Cache snapshot saved to %s in %s", path, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxRejectSamples limite le nombre d'erreurs conservées par table
+const maxRejectSamples = 20
+
+// RowError décrit une ligne rejetée lors du chargement d'une table
+type RowError struct {
+	Table string
+	Line  int
+	Err   error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("%s line %d: %v", e.Table, e.Line, e.Err)
+}
+
+// Rejects collecte les lignes rejetées d'une table pendant un chargement.
+// Un *Rejects nil ignore silencieusement les rejets.
+type Rejects struct {
+	Table   string
+	Count   int
+	Samples []RowError
+}
+
+// Add enregistre une ligne rejetée ; seuls les premiers échantillons sont gardés
+func (r *Rejects) Add(line int, err error) {
+	if r == nil {
+		return
+	}
+	r.Count++
+	if len(r.Samples) < maxRejectSamples {
+		r.Samples = append(r.Samples, RowError{Table: r.Table, Line: line, Err: err})
+	}
+}
+
+// TableReport résume le chargement d'une table lors d'un refresh
+type TableReport struct {
+	// Source est le nom de la base fédérée (vide sans fédération)
+	Source      string    `json:"source,omitempty"`
+	Table       string    `json:"table"`
+	Mode        string    `json:"mode"`
+	Rows        int       `json:"rows"`
+	Rejected    int       `json:"rejected"`
+	Samples     []string  `json:"reject_samples,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  float64   `json:"duration_ms"`
+	LastSuccess time.Time `json:"last_success"`
+}
+
+// OK indique si la table a été chargée sans erreur
+func (t TableReport) OK() bool {
+	return t.Error == ""
+}
+
+// RefreshReport résume un refresh complet du cache
+type RefreshReport struct {
+	Source     string        `json:"source"`
+	Version    uint64        `json:"version"`
+	StartedAt  time.Time     `json:"started_at"`
+	DurationMs float64       `json:"duration_ms"`
+	Tables     []TableReport `json:"tables"`
+}
+
+// Failed retourne les tables en erreur
+func (r *RefreshReport) Failed() []TableReport {
+	var failed []TableReport
+	for _, t := range r.Tables {
+		if !t.OK() {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// Rejected retourne le nombre total de lignes rejetées
+func (r *RefreshReport) Rejected() int {
+	total := 0
+	for _, t := range r.Tables {
+		total += t.Rejected
+	}
+	return total
+}
+
+// OldestSuccess retourne le plus ancien dernier succès parmi les tables
+// (zéro si une table n'a jamais été chargée)
+func (r *RefreshReport) OldestSuccess() time.Time {
+	var oldest time.Time
+	for i, t := range r.Tables {
+		if t.LastSuccess.IsZero() {
+			return time.Time{}
+		}
+		if i == 0 || t.LastSuccess.Before(oldest) {
+			oldest = t.LastSuccess
+		}
+	}
+	return oldest
+}
+
+// Err retourne une erreur listant les tables en échec, ou nil
+func (r *RefreshReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	msgs := make([]string, len(failed))
+	for i, t := range failed {
+		msgs[i] = t.Table + ": " + t.Error
+	}
+	return fmt.Errorf("%d table(s) failed to load: %s", len(failed), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// DataSource fournit les tables KPI qui alimentent le cache.
+// MySQLSource en est l'implémentation de production ; d'autres backends
+// (fixtures, snapshots fichiers, autres moteurs) peuvent s'y substituer.
+//
+// Chaque méthode doit abandonner dès que ctx est annulé, et signale dans
+// rej les lignes qu'elle a dû écarter ; une erreur retournée signifie que
+// la table entière n'a pas pu être lue.
+type DataSource interface {
+	// Name identifie la source dans les logs
+	Name() string
+
+	LoadSessions(ctx context.Context, rej *Rejects) ([]models.Session, error)
+	LoadAlertes(ctx context.Context, rej *Rejects) ([]models.Alerte, error)
+	LoadDefauts(ctx context.Context, rej *Rejects) ([]models.Defaut, error)
+	LoadSuspicious(ctx context.Context, rej *Rejects) ([]models.SuspiciousTransaction, error)
+	LoadMultiAttempts(ctx context.Context, rej *Rejects) ([]models.MultiAttempt, error)
+	LoadChargesMAC(ctx context.Context, rej *Rejects) ([]models.ChargeMAC, error)
+	LoadStatsGlobal(ctx context.Context, rej *Rejects) ([]models.StatsGlobal, error)
+	LoadChargesDaily(ctx context.Context, rej *Rejects) ([]models.ChargesDaily, error)
+	LoadDurationsSiteDaily(ctx context.Context, rej *Rejects) ([]models.DurationsSiteDaily, error)
+	LoadDurationsPDCDaily(ctx context.Context, rej *Rejects) ([]models.DurationsPDCDaily, error)
+}
+
+// IncrementalSessionSource est implémentée par les sources capables de ne
+// relire que les sessions récentes. Le cache fusionne alors ces lignes
+// (clé ID) avec les sessions déjà chargées au lieu de tout relire.
+type IncrementalSessionSource interface {
+	LoadSessionsSince(ctx context.Context, rej *Rejects, since time.Time) ([]models.Session, error)
+}
+
+// Vérifications à la compilation
+var (
+	_ DataSource               = (*MySQLSource)(nil)
+	_ IncrementalSessionSource = (*MySQLSource)(nil)
+)
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// taggedSource étiquette chaque ligne chargée avec le nom de sa base, pour
+// que les lignes de plusieurs bases fédérées restent distinguables une fois
+// fusionnées dans le cache
+type taggedSource struct {
+	DataSource
+	name string
+}
+
+// taggedIncrementalSource conserve la capacité incrémentale de la source
+// étiquetée
+type taggedIncrementalSource struct {
+	taggedSource
+	inc IncrementalSessionSource
+}
+
+// tagSource enveloppe source ; un nom vide la laisse telle quelle
+func tagSource(name string, source DataSource) DataSource {
+	if name == "" {
+		return source
+	}
+
+	t := taggedSource{DataSource: source, name: name}
+	if inc, ok := source.(IncrementalSessionSource); ok {
+		return taggedIncrementalSource{taggedSource: t, inc: inc}
+	}
+	return t
+}
+
+// Close ferme la source étiquetée si elle le permet
+func (t taggedSource) Close() error {
+	if closer, ok := t.DataSource.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (t taggedSource) LoadSessions(ctx context.Context, rej *Rejects) ([]models.Session, error) {
+	rows, err := t.DataSource.LoadSessions(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedIncrementalSource) LoadSessionsSince(ctx context.Context, rej *Rejects, since time.Time) ([]models.Session, error) {
+	rows, err := t.inc.LoadSessionsSince(ctx, rej, since)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadAlertes(ctx context.Context, rej *Rejects) ([]models.Alerte, error) {
+	rows, err := t.DataSource.LoadAlertes(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadDefauts(ctx context.Context, rej *Rejects) ([]models.Defaut, error) {
+	rows, err := t.DataSource.LoadDefauts(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadSuspicious(ctx context.Context, rej *Rejects) ([]models.SuspiciousTransaction, error) {
+	rows, err := t.DataSource.LoadSuspicious(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadMultiAttempts(ctx context.Context, rej *Rejects) ([]models.MultiAttempt, error) {
+	rows, err := t.DataSource.LoadMultiAttempts(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadChargesMAC(ctx context.Context, rej *Rejects) ([]models.ChargeMAC, error) {
+	rows, err := t.DataSource.LoadChargesMAC(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadStatsGlobal(ctx context.Context, rej *Rejects) ([]models.StatsGlobal, error) {
+	rows, err := t.DataSource.LoadStatsGlobal(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadChargesDaily(ctx context.Context, rej *Rejects) ([]models.ChargesDaily, error) {
+	rows, err := t.DataSource.LoadChargesDaily(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadDurationsSiteDaily(ctx context.Context, rej *Rejects) ([]models.DurationsSiteDaily, error) {
+	rows, err := t.DataSource.LoadDurationsSiteDaily(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
+
+func (t taggedSource) LoadDurationsPDCDaily(ctx context.Context, rej *Rejects) ([]models.DurationsPDCDaily, error) {
+	rows, err := t.DataSource.LoadDurationsPDCDaily(ctx, rej)
+	for i := range rows {
+		rows[i].Source = t.name
+	}
+	return rows, err
+}
@@ -0,0 +1,82 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+)
+
+// flushEvery fixe le nombre de lignes écrites entre deux envois au client
+const flushEvery = 1000
+
+// Rows parcourt les lignes d'un jeu de données : emit est appelé pour
+// chaque ligne et son erreur interrompt le parcours
+type Rows[T any] func(emit func(T) error) error
+
+// Slice parcourt une liste de lignes
+func Slice[T any](rows []T) Rows[T] {
+	return func(emit func(T) error) error {
+		for _, row := range rows {
+			if err := emit(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WriteCSV envoie les lignes en CSV au fil de l'eau, sans les accumuler :
+// l'en-tête HTTP part avec la première ligne, les suivantes sont envoyées
+// par paquets. Une erreur après le début de l'envoi ne peut plus être
+// signalée au client autrement qu'en coupant le fichier.
+func WriteCSV[T any](ctx context.Context, w http.ResponseWriter, filename string, format Format, fields []Field[T], rows Rows[T]) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format.BOM {
+		if _, err := w.Write([]byte("\ufeff")); err != nil {
+			return err
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = format.Comma
+
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.Header(format.Lang)
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	n := 0
+	err := rows(func(row T) error {
+		for i, f := range fields {
+			record[i] = format.Text(f.Value(row))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+
+		n++
+		if n%flushEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,129 @@
+// Package export écrit les jeux de données des onglets dans des fichiers
+// téléchargeables. Chaque jeu de données est décrit par ses champs (en-tête
+// français et anglais, valeur typée) ; le format (langue, séparateur,
+// virgule décimale) vient des paramètres de la requête.
+package export
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field est une colonne d'un jeu de données de T
+type Field[T any] struct {
+	FR string
+	EN string
+	// Value retourne une valeur typée : string, int, float64, bool,
+	// time.Time, ou nil pour une valeur absente
+	Value func(T) any
+}
+
+// Header retourne l'en-tête de la colonne dans la langue demandée
+func (f Field[T]) Header(lang string) string {
+	if lang == LangEN {
+		return f.EN
+	}
+	return f.FR
+}
+
+// Langues des en-têtes
+const (
+	LangFR = "fr"
+	LangEN = "en"
+)
+
+// Format décrit la mise en forme d'un export
+type Format struct {
+	Lang string
+	// Comma est le séparateur de colonnes
+	Comma rune
+	// DecimalComma écrit les nombres décimaux avec une virgule (Excel FR)
+	DecimalComma bool
+	// BOM préfixe le fichier d'un BOM UTF-8, pour qu'Excel détecte
+	// l'encodage
+	BOM bool
+}
+
+// ParseFormat lit lang (fr, en) et sep (";", ",", "tab") dans la requête.
+// Par défaut, en-têtes français et séparateur ";" : le format attendu par
+// Excel FR, avec virgule décimale et BOM.
+func ParseFormat(r *http.Request) Format {
+	f := Format{Lang: LangFR, Comma: ';'}
+	if strings.EqualFold(r.FormValue("lang"), LangEN) {
+		f.Lang = LangEN
+	}
+
+	switch r.FormValue("sep") {
+	case ",":
+		f.Comma = ','
+	case "tab", "\t":
+		f.Comma = '\t'
+	case ";":
+		f.Comma = ';'
+	}
+	f.DecimalComma = f.Comma == ';'
+	f.BOM = f.Comma == ';'
+	return f
+}
+
+// Text met en forme une valeur de champ pour un fichier texte
+func (f Format) Text(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case int:
+		return strconv.Itoa(x)
+	case float64:
+		s := strconv.FormatFloat(x, 'f', -1, 64)
+		if f.DecimalComma {
+			s = strings.Replace(s, ".", ",", 1)
+		}
+		return s
+	case bool:
+		switch {
+		case f.Lang == LangEN && x:
+			return "yes"
+		case f.Lang == LangEN:
+			return "no"
+		case x:
+			return "oui"
+		}
+		return "non"
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format("2006-01-02 15:04:05")
+	}
+	return ""
+}
+
+// Opt convertit un champ optionnel (valeur, présence) en valeur de champ
+func Opt[V int | float64](v V, ok bool) any {
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// Ptr convertit un pointeur (nil = absent) en valeur de champ
+func Ptr[V int | float64 | time.Time](p *V) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// Filename construit le nom du fichier téléchargé : jeu de données et
+// période exportée
+func Filename(dataset string, start, end time.Time, ext string) string {
+	name := dataset
+	if !start.IsZero() && !end.IsZero() {
+		name += "_" + start.Format("2006-01-02") + "_" + end.Format("2006-01-02")
+	}
+	return name + "." + ext
+}
@@ -0,0 +1,265 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatText(t *testing.T) {
+	fr := Format{Lang: LangFR, Comma: ';', DecimalComma: true}
+	en := Format{Lang: LangEN, Comma: ','}
+	at := time.Date(2024, time.March, 1, 8, 30, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		format Format
+		value  any
+		want   string
+	}{
+		{"absent", fr, nil, ""},
+		{"texte", fr, "Site A", "Site A"},
+		{"entier", fr, 8192, "8192"},
+		{"décimal FR", fr, 12.5, "12,5"},
+		{"décimal EN", en, 12.5, "12.5"},
+		{"décimal entier", fr, 40.0, "40"},
+		{"oui", fr, true, "oui"},
+		{"non", fr, false, "non"},
+		{"yes", en, true, "yes"},
+		{"no", en, false, "no"},
+		{"date", fr, at, "2024-03-01 08:30:05"},
+		{"date nulle", fr, time.Time{}, ""},
+		{"type inconnu", fr, []int{1}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.format.Text(tt.value); got != tt.want {
+			t.Errorf("%s: Text(%v) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Format
+	}{
+		{"", Format{Lang: LangFR, Comma: ';', DecimalComma: true, BOM: true}},
+		{"?lang=EN&sep=,", Format{Lang: LangEN, Comma: ','}},
+		{"?sep=tab", Format{Lang: LangFR, Comma: '\t'}},
+		{"?lang=de&sep=|", Format{Lang: LangFR, Comma: ';', DecimalComma: true, BOM: true}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/export"+tt.query, nil)
+		if got := ParseFormat(r); got != tt.want {
+			t.Errorf("ParseFormat(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	type row struct {
+		name   string
+		energy float64
+		ok     bool
+	}
+	fields := []Field[row]{
+		{"Nom", "Name", func(r row) any { return r.name }},
+		{"Énergie", "Energy", func(r row) any { return r.energy }},
+		{"Réussite", "Success", func(r row) any { return r.ok }},
+	}
+	rows := Slice([]row{{"A;1", 12.5, true}, {"B", 3, false}})
+
+	tests := []struct {
+		name   string
+		format Format
+		want   string
+	}{
+		{
+			name:   "Excel FR",
+			format: Format{Lang: LangFR, Comma: ';', DecimalComma: true, BOM: true},
+			want:   "\ufeffNom;Énergie;Réussite\n\"A;1\";12,5;oui\nB;3;non\n",
+		},
+		{
+			name:   "anglais",
+			format: Format{Lang: LangEN, Comma: ','},
+			want:   "Name,Energy,Success\nA;1,12.5,yes\nB,3,no\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := WriteCSV(context.Background(), w, "sessions.csv", tt.format, fields, rows); err != nil {
+				t.Fatalf("WriteCSV: %v", err)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if got, want := w.Header().Get("Content-Disposition"), `attachment; filename="sessions.csv"`; got != want {
+				t.Errorf("Content-Disposition = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFilename(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)
+	if got, want := Filename("sessions", start, end, "csv"), "sessions_2024-03-01_2024-03-31.csv"; got != want {
+		t.Errorf("Filename = %q, want %q", got, want)
+	}
+	if got, want := Filename("alertes", time.Time{}, end, "xlsx"), "alertes.xlsx"; got != want {
+		t.Errorf("Filename = %q, want %q", got, want)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 1: "B", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for i, want := range tests {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Sessions", "Sessions"},
+		{"Erreurs [EVI]: 1/2", "Erreurs -EVI-- 1-2"},
+		{"Une feuille dont le nom dépasse la limite", "Une feuille dont le nom dépasse"},
+		// La limite compte les caractères, pas les octets
+		{strings.Repeat("é", 40), strings.Repeat("é", 31)},
+	}
+	for _, tt := range tests {
+		if got := sheetName(tt.in); got != tt.want {
+			t.Errorf("sheetName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	paris := time.FixedZone("CEST", 2*3600)
+	tests := []struct {
+		in   time.Time
+		want float64
+	}{
+		{time.Date(1900, time.March, 1, 0, 0, 0, 0, time.UTC), 61},
+		{time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), 45352},
+		{time.Date(2024, time.March, 1, 18, 0, 0, 0, time.UTC), 45352.75},
+		// Heure locale de la date, sans conversion en UTC
+		{time.Date(2024, time.March, 1, 6, 0, 0, 0, paris), 45352.25},
+	}
+	for _, tt := range tests {
+		if got := excelDate(tt.in); got != tt.want {
+			t.Errorf("excelDate(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestWorkbook relit le classeur généré avec archive/zip
+func TestWorkbook(t *testing.T) {
+	type row struct {
+		name string
+		at   time.Time
+		n    any
+	}
+	fields := []Field[row]{
+		{"Nom", "Name", func(r row) any { return r.name }},
+		{"Date", "Date", func(r row) any { return r.at }},
+		{"Valeur", "Value", func(r row) any { return r.n }},
+	}
+
+	wb := &Workbook{Lang: LangEN}
+	AddSheet(wb, "Sessions", fields, []row{
+		{"A & B", time.Date(2024, time.March, 1, 18, 0, 0, 0, time.UTC), 12.5},
+		{"", time.Time{}, nil},
+	})
+	AddSheet(wb, "Alertes/Défauts", fields, nil)
+
+	var buf bytes.Buffer
+	if err := wb.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+
+	parts := make(map[string]string)
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		parts[f.Name] = string(content)
+	}
+
+	wantNames := []string{
+		"[Content_Types].xml",
+		"_rels/.rels",
+		"xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels",
+		"xl/styles.xml",
+		"xl/worksheets/sheet1.xml",
+		"xl/worksheets/sheet2.xml",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("parts = %v, want %v", names, wantNames)
+	}
+
+	// Chaque partie est du XML bien formé
+	for name, content := range parts {
+		d := xml.NewDecoder(strings.NewReader(content))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s: %v", name, err)
+				break
+			}
+		}
+	}
+
+	contains := []struct {
+		part string
+		want string
+	}{
+		{"[Content_Types].xml", `PartName="/xl/worksheets/sheet2.xml"`},
+		{"xl/workbook.xml", `<sheet name="Alertes-Défauts" sheetId="2" r:id="rId2"/>`},
+		{"xl/_rels/workbook.xml.rels", `Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"`},
+		{"xl/worksheets/sheet1.xml", `<c r="A1" t="inlineStr" s="2"><is><t xml:space="preserve">Name</t></is></c>`},
+		{"xl/worksheets/sheet1.xml", `<c r="A2" t="inlineStr"><is><t xml:space="preserve">A &amp; B</t></is></c>`},
+		{"xl/worksheets/sheet1.xml", `<c r="B2" s="1"><v>45352.75</v></c>`},
+		{"xl/worksheets/sheet1.xml", `<c r="C2"><v>12.5</v></c>`},
+		// Les valeurs absentes ne produisent pas de cellule
+		{"xl/worksheets/sheet1.xml", `<row r="3"></row>`},
+	}
+	for _, tt := range contains {
+		if !strings.Contains(parts[tt.part], tt.want) {
+			t.Errorf("%s does not contain %s:\n%s", tt.part, tt.want, parts[tt.part])
+		}
+	}
+}
+
+func TestWorkbookUnsupportedValue(t *testing.T) {
+	wb := &Workbook{Lang: LangFR}
+	AddSheet(wb, "S", []Field[int]{{"V", "V", func(int) any { return []int{1} }}}, []int{1})
+	if err := wb.Write(io.Discard); err == nil || !strings.Contains(err.Error(), "sheet S") {
+		t.Errorf("Write error = %v, want unsupported value in sheet S", err)
+	}
+}
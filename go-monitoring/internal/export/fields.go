@@ -0,0 +1,208 @@
+package export
+
+import (
+	"sort"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/utils"
+)
+
+// Champs exportés de chaque jeu de données
+
+var SessionFields = []Field[columnar.Row]{
+	{"ID", "ID", func(s columnar.Row) any { return s.ID() }},
+	{"Site", "Site", func(s columnar.Row) any { return s.Site() }},
+	{"PDC", "PDC", func(s columnar.Row) any { return s.PDC() }},
+	{"Début", "Start", func(s columnar.Row) any { return s.DatetimeStart() }},
+	{"Fin", "End", func(s columnar.Row) any {
+		if end, ok := s.DatetimeEnd(); ok {
+			return end
+		}
+		return nil
+	}},
+	{"Réussite", "Success", func(s columnar.Row) any { return s.OK() }},
+	{"Type d'erreur", "Error type", func(s columnar.Row) any { return s.TypeErreur() }},
+	{"Moment", "Moment", func(s columnar.Row) any { return s.Moment() }},
+	{"Moment avancé", "Detailed moment", func(s columnar.Row) any { return s.MomentAvancee() }},
+	{"Code EVI", "EVI error code", func(s columnar.Row) any { return Opt(s.EVIErrorCode()) }},
+	{"Étape EVI", "EVI status during error", func(s columnar.Row) any { return Opt(s.EVIMomentStep()) }},
+	{"Code Downstream PC", "Downstream code PC", func(s columnar.Row) any { return Opt(s.DownstreamCodePC()) }},
+	{"Énergie (kWh)", "Energy (kWh)", func(s columnar.Row) any { return Opt(s.EnergyKwh()) }},
+	{"Puissance moyenne (kW)", "Mean power (kW)", func(s columnar.Row) any { return Opt(s.MeanPowerKw()) }},
+	{"Puissance max (kW)", "Max power (kW)", func(s columnar.Row) any { return Opt(s.MaxPowerKw()) }},
+	{"SOC début", "SOC start", func(s columnar.Row) any { return Opt(s.SOCStart()) }},
+	{"SOC fin", "SOC end", func(s columnar.Row) any { return Opt(s.SOCEnd()) }},
+	{"MAC", "MAC address", func(s columnar.Row) any { return s.MACAddress() }},
+	{"Charge 900V", "900V charge", func(s columnar.Row) any { return s.Charge900V() }},
+	{"Base", "Source", func(s columnar.Row) any { return s.Source() }},
+}
+
+var AlerteFields = []Field[models.Alerte]{
+	{"Site", "Site", func(a models.Alerte) any { return a.Site }},
+	{"PDC", "PDC", func(a models.Alerte) any { return a.PDC }},
+	{"Type d'erreur", "Error type", func(a models.Alerte) any { return a.TypeErreur }},
+	{"Détection", "Detected at", func(a models.Alerte) any { return a.Detection }},
+	{"Occurrences 12h", "Occurrences (12h)", func(a models.Alerte) any { return a.Occurrences12h }},
+	{"Moment", "Moment", func(a models.Alerte) any { return a.Moment }},
+	{"Code EVI", "EVI error code", func(a models.Alerte) any { return Ptr(a.EVICode) }},
+	{"Code Downstream PC", "Downstream code PC", func(a models.Alerte) any { return Ptr(a.DownstreamCodePC) }},
+	{"Base", "Source", func(a models.Alerte) any { return a.Source }},
+}
+
+var DefautFields = []Field[models.Defaut]{
+	{"Site", "Site", func(d models.Defaut) any { return d.Site }},
+	{"Début", "Start", func(d models.Defaut) any { return d.DateDebut }},
+	{"Fin", "End", func(d models.Defaut) any { return Ptr(d.DateFin) }},
+	{"Défaut", "Defect", func(d models.Defaut) any { return d.Defaut }},
+	{"Équipement", "Equipment", func(d models.Defaut) any { return d.Equipement }},
+	{"Base", "Source", func(d models.Defaut) any { return d.Source }},
+}
+
+var SuspiciousFields = []Field[models.SuspiciousTransaction]{
+	{"ID", "ID", func(s models.SuspiciousTransaction) any { return s.ID }},
+	{"Site", "Site", func(s models.SuspiciousTransaction) any { return s.Site }},
+	{"PDC", "PDC", func(s models.SuspiciousTransaction) any { return s.PDC }},
+	{"MAC", "MAC address", func(s models.SuspiciousTransaction) any { return s.MACAddress }},
+	{"Véhicule", "Vehicle", func(s models.SuspiciousTransaction) any { return s.Vehicle }},
+	{"Début", "Start", func(s models.SuspiciousTransaction) any { return s.DatetimeStart }},
+	{"Fin", "End", func(s models.SuspiciousTransaction) any { return Ptr(s.DatetimeEnd) }},
+	{"Énergie (kWh)", "Energy (kWh)", func(s models.SuspiciousTransaction) any { return s.EnergyKwh }},
+	{"SOC début", "SOC start", func(s models.SuspiciousTransaction) any { return Ptr(s.SOCStart) }},
+	{"SOC fin", "SOC end", func(s models.SuspiciousTransaction) any { return Ptr(s.SOCEnd) }},
+	{"Base", "Source", func(s models.SuspiciousTransaction) any { return s.Source }},
+}
+
+var MultiAttemptFields = []Field[models.MultiAttempt]{
+	{"Site", "Site", func(m models.MultiAttempt) any { return m.Site }},
+	{"Heure", "Hour", func(m models.MultiAttempt) any { return m.Heure }},
+	{"MAC", "MAC address", func(m models.MultiAttempt) any { return m.MAC }},
+	{"Véhicule", "Vehicle", func(m models.MultiAttempt) any { return m.Vehicle }},
+	{"Tentatives", "Attempts", func(m models.MultiAttempt) any { return m.Tentatives }},
+	{"PDC(s)", "PDC(s)", func(m models.MultiAttempt) any { return m.PDCs }},
+	{"1ère tentative", "First attempt", func(m models.MultiAttempt) any { return m.PremiereTentative }},
+	{"Dernière tentative", "Last attempt", func(m models.MultiAttempt) any { return m.DerniereTentative }},
+	{"ID(s)", "ID(s)", func(m models.MultiAttempt) any { return m.IDs }},
+	{"SOC début min", "SOC start min", func(m models.MultiAttempt) any { return Ptr(m.SOCStartMin) }},
+	{"SOC début max", "SOC start max", func(m models.MultiAttempt) any { return Ptr(m.SOCStartMax) }},
+	{"SOC fin min", "SOC end min", func(m models.MultiAttempt) any { return Ptr(m.SOCEndMin) }},
+	{"SOC fin max", "SOC end max", func(m models.MultiAttempt) any { return Ptr(m.SOCEndMax) }},
+	{"Base", "Source", func(m models.MultiAttempt) any { return m.Source }},
+}
+
+var ChargeMACFields = []Field[models.ChargeMAC]{
+	{"ID", "ID", func(c models.ChargeMAC) any { return c.ID }},
+	{"Site", "Site", func(c models.ChargeMAC) any { return c.Site }},
+	{"MAC", "MAC address", func(c models.ChargeMAC) any { return c.MACAddress }},
+	{"Véhicule", "Vehicle", func(c models.ChargeMAC) any { return c.Vehicle }},
+	{"Début", "Start", func(c models.ChargeMAC) any { return c.DatetimeStart }},
+	{"SOC début", "SOC start", func(c models.ChargeMAC) any { return Ptr(c.SOCStart) }},
+	{"SOC fin", "SOC end", func(c models.ChargeMAC) any { return Ptr(c.SOCEnd) }},
+	{"Réussite", "Success", func(c models.ChargeMAC) any { return c.IsOK }},
+	{"Base", "Source", func(c models.ChargeMAC) any { return c.Source }},
+}
+
+var KPIFields = []Field[models.KPISummary]{
+	{"Total charges", "Total charges", func(k models.KPISummary) any { return k.Total }},
+	{"Réussite", "Success", func(k models.KPISummary) any { return k.OK }},
+	{"Échec", "Failure", func(k models.KPISummary) any { return k.NOK }},
+	{"Taux réussite (%)", "Success rate (%)", func(k models.KPISummary) any { return k.TauxReussite }},
+	{"Taux échec (%)", "Failure rate (%)", func(k models.KPISummary) any { return k.TauxEchec }},
+	{"Sites", "Sites", func(k models.KPISummary) any { return k.NbSites }},
+	{"PDC", "PDC", func(k models.KPISummary) any { return k.NbPDC }},
+}
+
+var MomentCountFields = []Field[models.MomentCount]{
+	{"Moment", "Moment", func(m models.MomentCount) any { return m.Moment }},
+	{"Échecs", "Failures", func(m models.MomentCount) any { return m.Count }},
+}
+
+var SiteStatsFields = []Field[models.SiteStats]{
+	{"Site", "Site", func(s models.SiteStats) any { return s.Site }},
+	{"Base", "Source", func(s models.SiteStats) any { return s.Source }},
+	{"Total", "Total", func(s models.SiteStats) any { return s.Total }},
+	{"Réussite", "Success", func(s models.SiteStats) any { return s.OK }},
+	{"Échec", "Failure", func(s models.SiteStats) any { return s.NOK }},
+	{"Taux réussite (%)", "Success rate (%)", func(s models.SiteStats) any { return s.TauxReussite }},
+	{"Taux échec (%)", "Failure rate (%)", func(s models.SiteStats) any { return s.TauxEchec }},
+}
+
+// PDCStatsRow est une ligne de stats par PDC, rattachée à son site
+type PDCStatsRow struct {
+	Site string
+	models.PDCStats
+}
+
+var PDCStatsFields = []Field[PDCStatsRow]{
+	{"Site", "Site", func(p PDCStatsRow) any { return p.Site }},
+	{"PDC", "PDC", func(p PDCStatsRow) any { return p.PDC }},
+	{"Total", "Total", func(p PDCStatsRow) any { return p.Total }},
+	{"Réussite", "Success", func(p PDCStatsRow) any { return p.OK }},
+	{"Échec", "Failure", func(p PDCStatsRow) any { return p.NOK }},
+	{"Taux réussite (%)", "Success rate (%)", func(p PDCStatsRow) any { return p.TauxReussite }},
+}
+
+// PDCStatsRows rattache les stats par PDC de chaque site, triées par site
+// puis PDC
+func PDCStatsRows(bySite map[string][]models.PDCStats) []PDCStatsRow {
+	var rows []PDCStatsRow
+	for site, stats := range bySite {
+		for _, s := range stats {
+			rows = append(rows, PDCStatsRow{Site: site, PDCStats: s})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Site != rows[j].Site {
+			return rows[i].Site < rows[j].Site
+		}
+		return rows[i].PDC < rows[j].PDC
+	})
+	return rows
+}
+
+// Familles de codes d'erreur
+const (
+	CodeEVI        = "EVI"
+	CodeDownstream = "Downstream"
+)
+
+// CodeRow est une ligne d'occurrences d'un code d'erreur
+type CodeRow struct {
+	Kind string
+	models.CodeOccurrence
+}
+
+// CodeFields liste les occurrences par code puis par moment, dans l'ordre
+// des moments de charge
+var CodeFields = codeFields()
+
+func codeFields() []Field[CodeRow] {
+	fields := []Field[CodeRow]{
+		{"Famille", "Kind", func(c CodeRow) any { return c.Kind }},
+		{"Code", "Code", func(c CodeRow) any { return c.Code }},
+		{"Occurrences", "Occurrences", func(c CodeRow) any { return c.Total }},
+		{"Part (%)", "Share (%)", func(c CodeRow) any { return c.Percentage }},
+	}
+	for _, moment := range utils.MomentOrder {
+		moment := moment
+		fields = append(fields, Field[CodeRow]{moment, moment, func(c CodeRow) any { return c.ByMoment[moment] }})
+	}
+	return fields
+}
+
+// CodeRows aplatit les occurrences EVI puis Downstream, triées par code
+func CodeRows(evi, downstream map[int]*models.CodeOccurrence) []CodeRow {
+	var rows []CodeRow
+	for _, family := range []struct {
+		kind  string
+		codes map[int]*models.CodeOccurrence
+	}{{CodeEVI, evi}, {CodeDownstream, downstream}} {
+		start := len(rows)
+		for _, occ := range family.codes {
+			rows = append(rows, CodeRow{Kind: family.kind, CodeOccurrence: *occ})
+		}
+		part := rows[start:]
+		sort.Slice(part, func(i, j int) bool { return part[i].Code < part[j].Code })
+	}
+	return rows
+}
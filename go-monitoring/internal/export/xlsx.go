@@ -0,0 +1,265 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentTypeXLSX est le type MIME d'un classeur Excel
+const ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Styles du classeur (index dans cellXfs de styles.xml)
+const (
+	styleDefault = 0
+	styleDate    = 1
+	styleHeader  = 2
+)
+
+// excelEpoch est l'origine des dates Excel (système 1900, bug du 29/02/1900
+// compris)
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// Workbook est un classeur XLSX dont chaque feuille est écrite au moment de
+// l'envoi, sans passer par une grille intermédiaire
+type Workbook struct {
+	Lang   string
+	sheets []sheet
+}
+
+type sheet struct {
+	name string
+	// write écrit les lignes de la feuille, en-tête compris
+	write func(sw *sheetWriter) error
+}
+
+// AddSheet ajoute une feuille listant rows selon fields. Le nom est limité
+// à 31 caractères par Excel.
+func AddSheet[T any](wb *Workbook, name string, fields []Field[T], rows []T) {
+	lang := wb.Lang
+	wb.sheets = append(wb.sheets, sheet{
+		name: name,
+		write: func(sw *sheetWriter) error {
+			header := make([]any, len(fields))
+			for i, f := range fields {
+				header[i] = f.Header(lang)
+			}
+			if err := sw.row(header, styleHeader); err != nil {
+				return err
+			}
+
+			values := make([]any, len(fields))
+			for _, row := range rows {
+				for i, f := range fields {
+					values[i] = f.Value(row)
+				}
+				if err := sw.row(values, styleDefault); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// WriteXLSX envoie le classeur en pièce jointe
+func WriteXLSX(w http.ResponseWriter, filename string, wb *Workbook) error {
+	w.Header().Set("Content-Type", ContentTypeXLSX)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	return wb.Write(w)
+}
+
+// Write écrit le classeur (archive zip SpreadsheetML)
+func (wb *Workbook) Write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", wb.contentTypes()},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", wb.workbook()},
+		{"xl/_rels/workbook.xml.rels", wb.workbookRels()},
+		{"xl/styles.xml", styles},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return err
+		}
+	}
+
+	for i, s := range wb.sheets {
+		f, err := zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		sw := &sheetWriter{w: bufio.NewWriter(f)}
+		sw.w.WriteString(xml.Header)
+		sw.w.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+		// En-tête figé
+		sw.w.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+		sw.w.WriteString(`<sheetData>`)
+		if err := s.write(sw); err != nil {
+			return fmt.Errorf("sheet %s: %w", s.name, err)
+		}
+		sw.w.WriteString(`</sheetData></worksheet>`)
+		if err := sw.w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func (wb *Workbook) contentTypes() string {
+	s := xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`
+	for i := range wb.sheets {
+		s += fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	return s + `</Types>`
+}
+
+func (wb *Workbook) workbook() string {
+	s := xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`
+	for i, sh := range wb.sheets {
+		s += fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheetName(sh.name)), i+1, i+1)
+	}
+	return s + `</sheets></workbook>`
+}
+
+func (wb *Workbook) workbookRels() string {
+	s := xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`
+	for i := range wb.sheets {
+		s += fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	// Les styles suivent les feuilles
+	s += fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(wb.sheets)+1)
+	return s + `</Relationships>`
+}
+
+const rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// styles déclare les trois styles de cellule : défaut, date et en-tête gras
+const styles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// sheetWriter écrit les lignes d'une feuille
+type sheetWriter struct {
+	w *bufio.Writer
+	n int
+}
+
+// row écrit une ligne de cellules typées ; style s'applique aux cellules
+// qui n'ont pas de style propre (les dates)
+func (sw *sheetWriter) row(values []any, style int) error {
+	sw.n++
+	fmt.Fprintf(sw.w, `<row r="%d">`, sw.n)
+	for i, v := range values {
+		ref := columnName(i) + strconv.Itoa(sw.n)
+		switch x := v.(type) {
+		case nil:
+			continue
+		case string:
+			if x == "" {
+				continue
+			}
+			fmt.Fprintf(sw.w, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr(style), escape(x))
+		case int:
+			fmt.Fprintf(sw.w, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr(style), x)
+		case float64:
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				continue
+			}
+			fmt.Fprintf(sw.w, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr(style), strconv.FormatFloat(x, 'f', -1, 64))
+		case bool:
+			b := 0
+			if x {
+				b = 1
+			}
+			fmt.Fprintf(sw.w, `<c r="%s" t="b"%s><v>%d</v></c>`, ref, styleAttr(style), b)
+		case time.Time:
+			if x.IsZero() {
+				continue
+			}
+			fmt.Fprintf(sw.w, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr(styleDate), strconv.FormatFloat(excelDate(x), 'f', -1, 64))
+		default:
+			return fmt.Errorf("unsupported cell value %T", v)
+		}
+	}
+	_, err := sw.w.WriteString(`</row>`)
+	return err
+}
+
+func styleAttr(style int) string {
+	if style == styleDefault {
+		return ""
+	}
+	return fmt.Sprintf(` s="%d"`, style)
+}
+
+// excelDate convertit une date en numéro de série Excel, à l'heure locale
+// de la date (Excel ne connaît pas les fuseaux)
+func excelDate(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return wall.Sub(excelEpoch).Hours() / 24
+}
+
+// columnName retourne la lettre de colonne Excel (0 → A, 26 → AA)
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName retire les caractères interdits et tronque à 31 caractères
+func sheetName(name string) string {
+	out := make([]rune, 0, len(name))
+	for _, r := range name {
+		switch r {
+		case '[', ']', ':', '*', '?', '/', '\\':
+			r = '-'
+		}
+		out = append(out, r)
+		if len(out) == 31 {
+			break
+		}
+	}
+	return string(out)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
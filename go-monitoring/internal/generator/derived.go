@@ -0,0 +1,351 @@
+package generator
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// Tables dérivées des sessions, dans l'esprit des requêtes KPI
+
+// chargesMAC liste les charges des véhicules identifiés par leur MAC
+func chargesMAC(names map[string]string, sessions []models.Session) []models.ChargeMAC {
+	charges := make([]models.ChargeMAC, 0, len(sessions))
+	for _, s := range sessions {
+		if s.MACAddress == "" {
+			continue
+		}
+		charges = append(charges, models.ChargeMAC{
+			ID:            s.ID,
+			Site:          s.Site,
+			MACAddress:    s.MACAddress,
+			Vehicle:       names[s.MACAddress],
+			DatetimeStart: s.DatetimeStart,
+			SOCStart:      s.SOCStart,
+			SOCEnd:        s.SOCEnd,
+			IsOK:          s.StateOfCharge == 0,
+		})
+	}
+	return charges
+}
+
+// suspicious liste les charges réussies de moins de 1 kWh
+func suspicious(names map[string]string, sessions []models.Session) []models.SuspiciousTransaction {
+	var out []models.SuspiciousTransaction
+	for _, s := range sessions {
+		if s.StateOfCharge != 0 || s.EnergyKwh == nil || *s.EnergyKwh >= 1 {
+			continue
+		}
+		out = append(out, models.SuspiciousTransaction{
+			ID:            s.ID,
+			Site:          s.Site,
+			PDC:           s.PDC,
+			MACAddress:    s.MACAddress,
+			Vehicle:       names[s.MACAddress],
+			DatetimeStart: s.DatetimeStart,
+			DatetimeEnd:   s.DatetimeEnd,
+			EnergyKwh:     *s.EnergyKwh,
+			SOCStart:      s.SOCStart,
+			SOCEnd:        s.SOCEnd,
+		})
+	}
+	return out
+}
+
+// multiAttempts regroupe les tentatives d'un même véhicule sur un même site
+// dans l'heure qui suit la première
+func multiAttempts(names map[string]string, sessions []models.Session) []models.MultiAttempt {
+	type key struct{ site, mac string }
+	groups := make(map[key][]models.Session)
+	var order []key
+	var out []models.MultiAttempt
+
+	flush := func(k key) {
+		g := groups[k]
+		delete(groups, k)
+		if len(g) < 2 {
+			return
+		}
+
+		first, last := g[0], g[len(g)-1]
+		m := models.MultiAttempt{
+			Site:              k.site,
+			Heure:             first.DatetimeStart.Truncate(time.Hour).Format("2006-01-02 15:04"),
+			MAC:               k.mac,
+			Vehicle:           names[k.mac],
+			Tentatives:        len(g),
+			PremiereTentative: first.DatetimeStart,
+			DerniereTentative: last.DatetimeStart,
+		}
+		var pdcs, ids []string
+		for _, s := range g {
+			if !contains(pdcs, s.PDC) {
+				pdcs = append(pdcs, s.PDC)
+			}
+			ids = append(ids, s.ID)
+			m.SOCStartMin, m.SOCStartMax = minMax(m.SOCStartMin, m.SOCStartMax, s.SOCStart)
+			m.SOCEndMin, m.SOCEndMax = minMax(m.SOCEndMin, m.SOCEndMax, s.SOCEnd)
+		}
+		m.PDCs = strings.Join(pdcs, ", ")
+		m.IDs = strings.Join(ids, ", ")
+		out = append(out, m)
+	}
+
+	for _, s := range sessions {
+		if s.MACAddress == "" {
+			continue
+		}
+		k := key{s.Site, s.MACAddress}
+		if g, ok := groups[k]; ok && s.DatetimeStart.Sub(g[0].DatetimeStart) > time.Hour {
+			flush(k)
+		}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], s)
+	}
+	for _, k := range order {
+		if _, ok := groups[k]; ok {
+			flush(k)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].PremiereTentative.Before(out[j].PremiereTentative)
+	})
+	return out
+}
+
+func minMax(lo, hi, v *float64) (*float64, *float64) {
+	if v == nil {
+		return lo, hi
+	}
+	if lo == nil || *v < *lo {
+		lo = v
+	}
+	if hi == nil || *v > *hi {
+		hi = v
+	}
+	return lo, hi
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// alerteThreshold est le nombre d'échecs d'un PDC en 12 h qui lève une
+// alerte
+const alerteThreshold = 3
+
+// alertes lève une alerte quand un PDC échoue au moins alerteThreshold
+// fois en 12 h : la détection a lieu au dernier échec du seuil, les
+// occurrences couvrent les 12 h depuis le premier
+func alertes(sessions []models.Session) []models.Alerte {
+	type key struct{ site, pdc string }
+	failures := make(map[key][]models.Session)
+	var keys []key
+	for _, s := range sessions {
+		if s.StateOfCharge == 0 {
+			continue
+		}
+		k := key{s.Site, s.PDC}
+		if _, ok := failures[k]; !ok {
+			keys = append(keys, k)
+		}
+		failures[k] = append(failures[k], s)
+	}
+
+	var out []models.Alerte
+	for _, k := range keys {
+		f := failures[k]
+		for i := 0; i+alerteThreshold <= len(f); {
+			windowEnd := f[i].DatetimeStart.Add(12 * time.Hour)
+			if !f[i+alerteThreshold-1].DatetimeStart.Before(windowEnd) {
+				i++
+				continue
+			}
+
+			n := i
+			for n < len(f) && f[n].DatetimeStart.Before(windowEnd) {
+				n++
+			}
+			last := f[i+alerteThreshold-1]
+			out = append(out, models.Alerte{
+				Site:             k.site,
+				PDC:              k.pdc,
+				TypeErreur:       last.TypeErreur,
+				Detection:        last.DatetimeStart,
+				Occurrences12h:   n - i,
+				Moment:           last.Moment,
+				EVICode:          last.EVIErrorCode,
+				DownstreamCodePC: last.DownstreamCodePC,
+			})
+			i = n
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Detection.Before(out[j].Detection) })
+	return out
+}
+
+var defautKinds = []struct {
+	label  string
+	eqp    string
+	weight float64
+	// hours est la durée typique du défaut
+	hours float64
+}{
+	{"Perte de communication", "Routeur", 4, 2},
+	{"Défaut d'isolement", "PDC", 3, 8},
+	{"Surchauffe câble", "PDC", 2, 4},
+	{"Arrêt d'urgence", "PDC", 2, 0.5},
+	{"Défaut disjoncteur", "Armoire", 1, 24},
+	{"Défaut module de puissance", "Armoire", 1, 48},
+}
+
+// defauts tire les défauts d'équipement des sites : environ un tous les
+// dix jours par site, les plus récents pouvant être encore actifs
+func defauts(rng *rand.Rand, sites []site, cfg Config) []models.Defaut {
+	weights := make([]float64, len(defautKinds))
+	for i, d := range defautKinds {
+		weights[i] = d.weight
+	}
+
+	start := cfg.Start()
+	period := cfg.End.Sub(start)
+	var out []models.Defaut
+	for _, s := range sites {
+		for n := poisson(rng, float64(cfg.Days)/10); n > 0; n-- {
+			kind := defautKinds[weighted(rng, weights)]
+			begin := start.Add(time.Duration(rng.Int63n(int64(period)))).Truncate(time.Second)
+			eqp := kind.eqp
+			if eqp == "PDC" {
+				eqp = s.pdcs[rng.Intn(len(s.pdcs))].name
+			}
+
+			d := models.Defaut{Site: s.name, DateDebut: begin, Defaut: kind.label, Equipement: eqp}
+			end := begin.Add(time.Duration(kind.hours * between(rng, 0.2, 2) * float64(time.Hour))).Truncate(time.Second)
+			if end.Before(cfg.End) {
+				d.DateFin = &end
+			}
+			out = append(out, d)
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].DateDebut.Before(out[j].DateDebut) })
+	return out
+}
+
+// statsGlobal calcule le taux de réussite mensuel
+func statsGlobal(sessions []models.Session) []models.StatsGlobal {
+	type count struct{ ok, total int }
+	months := make(map[string]*count)
+	for _, s := range sessions {
+		m := s.DatetimeStart.Format("2006-01")
+		if months[m] == nil {
+			months[m] = &count{}
+		}
+		months[m].total++
+		if s.StateOfCharge == 0 {
+			months[m].ok++
+		}
+	}
+
+	out := make([]models.StatsGlobal, 0, len(months))
+	for m, c := range months {
+		out = append(out, models.StatsGlobal{Mois: m, TauxReussite: round(float64(c.ok)/float64(c.total)*100, 2)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Mois < out[j].Mois })
+	return out
+}
+
+// chargesDaily compte les charges réussies et en échec par site et par jour
+func chargesDaily(sessions []models.Session) []models.ChargesDaily {
+	type key struct {
+		site   string
+		day    time.Time
+		status string
+	}
+	counts := make(map[key]int)
+	var keys []key
+	for _, s := range sessions {
+		k := key{s.Site, day(s.DatetimeStart), "OK"}
+		if s.StateOfCharge != 0 {
+			k.status = "NOK"
+		}
+		if counts[k] == 0 {
+			keys = append(keys, k)
+		}
+		counts[k]++
+	}
+
+	out := make([]models.ChargesDaily, len(keys))
+	for i, k := range keys {
+		out[i] = models.ChargesDaily{Site: k.site, Day: k.day, Status: k.status, Nb: counts[k]}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].Day.Equal(out[j].Day) {
+			return out[i].Day.Before(out[j].Day)
+		}
+		if out[i].Site != out[j].Site {
+			return out[i].Site < out[j].Site
+		}
+		return out[i].Status < out[j].Status
+	})
+	return out
+}
+
+// durations cumule les minutes de charge par site et par PDC, chaque jour
+func durations(sessions []models.Session) ([]models.DurationsSiteDaily, []models.DurationsPDCDaily) {
+	type siteKey struct {
+		site string
+		day  time.Time
+	}
+	type pdcKey struct {
+		site, pdc string
+		day       time.Time
+	}
+	bySite := make(map[siteKey]float64)
+	byPDC := make(map[pdcKey]float64)
+	var siteKeys []siteKey
+	var pdcKeys []pdcKey
+
+	for _, s := range sessions {
+		if s.DatetimeEnd == nil {
+			continue
+		}
+		minutes := s.DatetimeEnd.Sub(s.DatetimeStart).Minutes()
+		sk := siteKey{s.Site, day(s.DatetimeStart)}
+		pk := pdcKey{s.Site, s.PDC, sk.day}
+		if _, ok := bySite[sk]; !ok {
+			siteKeys = append(siteKeys, sk)
+		}
+		if _, ok := byPDC[pk]; !ok {
+			pdcKeys = append(pdcKeys, pk)
+		}
+		bySite[sk] += minutes
+		byPDC[pk] += minutes
+	}
+
+	sites := make([]models.DurationsSiteDaily, len(siteKeys))
+	for i, k := range siteKeys {
+		sites[i] = models.DurationsSiteDaily{Site: k.site, Day: k.day, DurMin: round(bySite[k], 1)}
+	}
+	pdcs := make([]models.DurationsPDCDaily, len(pdcKeys))
+	for i, k := range pdcKeys {
+		pdcs[i] = models.DurationsPDCDaily{Site: k.site, PDC: k.pdc, Day: k.day, DurMin: round(byPDC[k], 1)}
+	}
+	return sites, pdcs
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
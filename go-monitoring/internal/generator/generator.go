@@ -0,0 +1,176 @@
+// Package generator produit un jeu de données KPI synthétique mais
+// réaliste (sites et PDC, flotte de véhicules, heures de pointe, moments et
+// codes d'erreur), pour les démonstrations et les tests de charge sans
+// données de production.
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// Config règle la taille et la période du jeu de données
+type Config struct {
+	// Sites est le nombre de sites
+	Sites int
+	// MaxPDC borne le nombre de PDC par site (au moins 2 par site)
+	MaxPDC int
+	// Days est la durée de la période générée, qui se termine à End
+	Days int
+	// SessionsPerPDC est le nombre moyen de sessions par PDC et par jour
+	SessionsPerPDC float64
+	// Vehicles est la taille de la flotte (0 = proportionnelle au trafic)
+	Vehicles int
+	// End est la fin (exclue) de la période, à minuit
+	End time.Time
+	// Seed rend la génération reproductible
+	Seed int64
+}
+
+// DefaultConfig retourne un jeu de données de démonstration : 12 sites,
+// 90 jours jusqu'à aujourd'hui inclus
+func DefaultConfig() Config {
+	now := time.Now()
+	return Config{
+		Sites:          12,
+		MaxPDC:         6,
+		Days:           90,
+		SessionsPerPDC: 6,
+		End:            time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC),
+		Seed:           1,
+	}
+}
+
+// Validate vérifie la configuration
+func (c Config) Validate() error {
+	var errs []error
+	if c.Sites <= 0 {
+		errs = append(errs, errors.New("sites must be positive"))
+	}
+	if c.MaxPDC < 2 {
+		errs = append(errs, errors.New("max pdc must be at least 2"))
+	}
+	if c.Days <= 0 {
+		errs = append(errs, errors.New("days must be positive"))
+	}
+	if c.SessionsPerPDC <= 0 {
+		errs = append(errs, errors.New("sessions per pdc must be positive"))
+	}
+	if c.Vehicles < 0 {
+		errs = append(errs, errors.New("vehicles must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Start retourne le début de la période
+func (c Config) Start() time.Time {
+	return c.End.AddDate(0, 0, -c.Days)
+}
+
+// Dataset contient toutes les tables KPI générées
+type Dataset struct {
+	Sessions           []models.Session
+	Alertes            []models.Alerte
+	Defauts            []models.Defaut
+	Suspicious         []models.SuspiciousTransaction
+	MultiAttempts      []models.MultiAttempt
+	ChargesMAC         []models.ChargeMAC
+	StatsGlobal        []models.StatsGlobal
+	ChargesDaily       []models.ChargesDaily
+	DurationsSiteDaily []models.DurationsSiteDaily
+	DurationsPDCDaily  []models.DurationsPDCDaily
+}
+
+// Generate simule les sessions de la période puis en dérive les autres
+// tables, comme le font les requêtes KPI à partir des sessions
+func Generate(cfg Config) (*Dataset, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	sites := newSites(rng, cfg)
+	fleet := newFleet(rng, cfg)
+
+	sim := simulator{rng: rng, cfg: cfg, fleet: fleet}
+	for day := cfg.Start(); day.Before(cfg.End); day = day.AddDate(0, 0, 1) {
+		for _, s := range sites {
+			sim.day(s, day)
+		}
+	}
+
+	sessions := sim.sessions
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].DatetimeStart.Before(sessions[j].DatetimeStart)
+	})
+	for i := range sessions {
+		sessions[i].ID = fmt.Sprintf("%d", 100001+i)
+	}
+
+	names := vehicleNames(fleet)
+	ds := &Dataset{Sessions: sessions}
+	ds.ChargesMAC = chargesMAC(names, sessions)
+	ds.Suspicious = suspicious(names, sessions)
+	ds.MultiAttempts = multiAttempts(names, sessions)
+	ds.Alertes = alertes(sessions)
+	ds.Defauts = defauts(rng, sites, cfg)
+	ds.StatsGlobal = statsGlobal(sessions)
+	ds.ChargesDaily = chargesDaily(sessions)
+	ds.DurationsSiteDaily, ds.DurationsPDCDaily = durations(sessions)
+	return ds, nil
+}
+
+// poisson tire un nombre d'événements de moyenne lambda
+func poisson(rng *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	// Approximation normale au-delà de 30 événements
+	if lambda > 30 {
+		n := int(math.Round(lambda + math.Sqrt(lambda)*rng.NormFloat64()))
+		if n < 0 {
+			return 0
+		}
+		return n
+	}
+
+	limit := math.Exp(-lambda)
+	n, p := 0, rng.Float64()
+	for p > limit {
+		n++
+		p *= rng.Float64()
+	}
+	return n
+}
+
+// between tire un réel uniforme dans [min, max)
+func between(rng *rand.Rand, min, max float64) float64 {
+	return min + rng.Float64()*(max-min)
+}
+
+// weighted tire un indice selon des poids
+func weighted(rng *rand.Rand, weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	x := rng.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return i
+		}
+		x -= w
+	}
+	return len(weights) - 1
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
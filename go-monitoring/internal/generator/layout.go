@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// site est une station de recharge et ses PDC
+type site struct {
+	name string
+	pdcs []pdc
+	// traffic pondère la fréquentation du site (autoroute, centre-ville...)
+	traffic float64
+}
+
+// pdc est un point de charge
+type pdc struct {
+	name  string
+	maxKW float64
+	// reliability divise le taux d'échec : un PDC fatigué est sous 1
+	reliability float64
+}
+
+var siteNames = []string{
+	"Carvin", "Lens", "Arras", "Douai", "Béthune", "Valenciennes", "Cambrai", "Lille Sud",
+	"Dunkerque", "Calais", "Boulogne", "Saint-Omer", "Amiens", "Beauvais", "Compiègne", "Reims",
+	"Troyes", "Metz", "Nancy", "Strasbourg", "Mulhouse", "Dijon", "Besançon", "Orléans",
+}
+
+// Classes de puissance des sites : les plus récents sont en très haute
+// puissance
+var powerClasses = []struct {
+	kw     float64
+	weight float64
+}{
+	{50, 1},
+	{150, 3},
+	{300, 2},
+}
+
+func newSites(rng *rand.Rand, cfg Config) []site {
+	powerWeights := make([]float64, len(powerClasses))
+	for i, c := range powerClasses {
+		powerWeights[i] = c.weight
+	}
+
+	sites := make([]site, cfg.Sites)
+	for i := range sites {
+		name := fmt.Sprintf("Site %d", i+1)
+		if i < len(siteNames) {
+			name = siteNames[i]
+		}
+
+		kw := powerClasses[weighted(rng, powerWeights)].kw
+		n := 2 + rng.Intn(cfg.MaxPDC-1)
+		s := site{name: name, traffic: between(rng, 0.5, 1.5), pdcs: make([]pdc, n)}
+		for j := range s.pdcs {
+			reliability := between(rng, 0.8, 1.3)
+			// Un PDC sur dix est nettement moins fiable que les autres
+			if rng.Float64() < 0.1 {
+				reliability = between(rng, 0.3, 0.6)
+			}
+			s.pdcs[j] = pdc{name: fmt.Sprintf("PDC%d", j+1), maxKW: kw, reliability: reliability}
+		}
+		sites[i] = s
+	}
+	return sites
+}
+
+// model est un modèle de véhicule électrique
+type model struct {
+	name       string
+	share      float64
+	batteryKWh float64
+	maxKW      float64
+	v900       bool
+	// oui est le préfixe constructeur des adresses MAC du modèle
+	oui string
+	// risk multiplie le taux d'échec du modèle (compatibilité des bornes)
+	risk float64
+}
+
+// vehicleModels reproduit un parc français typique
+var vehicleModels = []model{
+	{"Tesla Model 3", 14, 60, 170, false, "4c:fc:aa", 0.7},
+	{"Tesla Model Y", 12, 75, 250, false, "4c:fc:aa", 0.7},
+	{"Renault Zoe", 11, 52, 50, false, "00:1e:0e", 1.6},
+	{"Renault Megane E-Tech", 8, 60, 130, false, "00:1e:0e", 1.1},
+	{"Peugeot e-208", 10, 50, 100, false, "70:b3:d5", 1.3},
+	{"Volkswagen ID.3", 8, 58, 120, false, "00:7d:fa", 1.0},
+	{"Volkswagen ID.4", 6, 77, 135, false, "00:7d:fa", 1.0},
+	{"Hyundai Ioniq 5", 6, 77, 230, true, "e8:eb:1b", 0.9},
+	{"Kia EV6", 5, 77, 230, true, "e8:eb:1b", 0.9},
+	{"Dacia Spring", 6, 27, 30, false, "00:1e:0e", 1.4},
+	{"BMW i4", 4, 84, 200, false, "00:01:a9", 0.8},
+	{"Porsche Taycan", 2, 93, 270, true, "00:0f:e6", 1.2},
+	{"Fiat 500e", 4, 42, 85, false, "00:1b:8f", 1.2},
+	{"MG4", 4, 64, 135, false, "34:81:f4", 1.1},
+}
+
+// vehicle est un véhicule de la flotte, identifié par son adresse MAC
+type vehicle struct {
+	model *model
+	mac   string
+}
+
+func newFleet(rng *rand.Rand, cfg Config) []vehicle {
+	n := cfg.Vehicles
+	if n == 0 {
+		// Un véhicule revient en moyenne tous les quinze jours environ
+		n = int(float64(cfg.Sites*cfg.MaxPDC) * cfg.SessionsPerPDC * 2)
+		if n < 50 {
+			n = 50
+		}
+	}
+
+	shares := make([]float64, len(vehicleModels))
+	for i, m := range vehicleModels {
+		shares[i] = m.share
+	}
+
+	fleet := make([]vehicle, 0, n)
+	seen := make(map[string]bool, n)
+	for len(fleet) < n {
+		m := &vehicleModels[weighted(rng, shares)]
+		mac := fmt.Sprintf("%s:%02x:%02x:%02x", m.oui, rng.Intn(256), rng.Intn(256), rng.Intn(256))
+		if seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		fleet = append(fleet, vehicle{model: m, mac: mac})
+	}
+	return fleet
+}
+
+// vehicleNames associe chaque adresse MAC au modèle du véhicule
+func vehicleNames(fleet []vehicle) map[string]string {
+	names := make(map[string]string, len(fleet))
+	for _, v := range fleet {
+		names[v.mac] = v.model.name
+	}
+	return names
+}
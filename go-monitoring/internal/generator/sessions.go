@@ -0,0 +1,189 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// Fréquentation relative par heure de la journée : creux de nuit, pointes
+// du matin, du midi et du retour du travail
+var hourWeights = [24]float64{
+	0.2, 0.1, 0.1, 0.1, 0.1, 0.2, 0.5, 1.0, 1.4, 1.2, 1.0, 1.1,
+	1.5, 1.4, 1.1, 1.0, 1.2, 1.6, 1.7, 1.4, 1.0, 0.7, 0.5, 0.3,
+}
+
+// Fréquentation relative par jour de la semaine (dimanche d'abord)
+var weekdayWeights = [7]float64{0.75, 1.0, 1.0, 1.0, 1.05, 1.1, 0.9}
+
+// baseFailureRate est le taux d'échec d'un PDC et d'un véhicule moyens
+const baseFailureRate = 0.09
+
+// failure décrit les erreurs possibles à un moment de la charge
+type failure struct {
+	moment string
+	weight float64
+	// steps sont les moments avancés et les étapes EVI correspondantes
+	steps []step
+	// evi est la part des erreurs remontées par l'EVI (le reste par le
+	// Downstream)
+	evi      float64
+	eviCodes []code
+	dsCodes  []code
+}
+
+type step struct {
+	label string
+	evi   int
+}
+
+type code struct {
+	value  int
+	weight float64
+}
+
+var failures = []failure{
+	{"Init", 30, []step{{"Init - Communication", 1}, {"Init - Authentification", 2}, {"Init - Paramètres", 3}},
+		0.5, []code{{3, 5}, {7, 2}, {12, 1}}, []code{{2, 4}, {8, 2}, {1024, 1}}},
+	{"Lock Connector", 10, []step{{"Verrouillage connecteur", 4}},
+		0.8, []code{{21, 4}, {22, 1}}, []code{{4, 1}}},
+	{"CableCheck", 20, []step{{"CableCheck - Isolement", 5}, {"CableCheck - Précharge", 6}},
+		0.7, []code{{31, 5}, {32, 3}, {34, 1}}, []code{{16, 3}, {64, 1}}},
+	{"Charge", 30, []step{{"Charge - Régulation", 7}, {"Charge - Température", 7}, {"Charge - Communication", 7}},
+		0.4, []code{{41, 3}, {45, 2}, {48, 1}}, []code{{32, 4}, {128, 2}, {8192, 1}}},
+	{"Fin de charge", 7, []step{{"Fin de charge - Déverrouillage", 8}, {"Fin de charge - Arrêt", 8}},
+		0.5, []code{{51, 2}, {52, 1}}, []code{{256, 2}, {512, 1}}},
+	{"Unknown", 3, []step{{"Unknown", 0}},
+		0.5, []code{{99, 1}}, []code{{1, 1}}},
+}
+
+// simulator génère les sessions jour par jour
+type simulator struct {
+	rng   *rand.Rand
+	cfg   Config
+	fleet []vehicle
+
+	sessions []models.Session
+}
+
+// day génère les sessions d'un site pour une journée
+func (s *simulator) day(st site, day time.Time) {
+	lambda := s.cfg.SessionsPerPDC * float64(len(st.pdcs)) * st.traffic * weekdayWeights[day.Weekday()]
+	hours := hourWeights[:]
+
+	for n := poisson(s.rng, lambda); n > 0; n-- {
+		start := day.Add(time.Duration(weighted(s.rng, hours))*time.Hour +
+			time.Duration(s.rng.Intn(3600))*time.Second)
+		v := s.fleet[s.rng.Intn(len(s.fleet))]
+		p := st.pdcs[s.rng.Intn(len(st.pdcs))]
+
+		// Après un échec avant la charge, le conducteur réessaie souvent
+		// quelques minutes plus tard, sur le même PDC ou un autre
+		for attempt := 1; ; attempt++ {
+			sess := s.charge(st, p, v, start)
+			if sess.StateOfCharge == 0 || attempt == 4 || s.rng.Float64() > 0.55 || !beforeCharge(sess.Moment) {
+				break
+			}
+			start = sess.DatetimeStart.Add(time.Duration(60+s.rng.Intn(420)) * time.Second)
+			if !start.Before(s.cfg.End) {
+				break
+			}
+			if s.rng.Float64() < 0.5 {
+				p = st.pdcs[s.rng.Intn(len(st.pdcs))]
+			}
+		}
+	}
+}
+
+func beforeCharge(moment string) bool {
+	return moment == "Init" || moment == "Lock Connector" || moment == "CableCheck"
+}
+
+// charge simule une session et l'ajoute au jeu de données
+func (s *simulator) charge(st site, p pdc, v vehicle, start time.Time) models.Session {
+	rng := s.rng
+	m := v.model
+	sess := models.Session{
+		DatetimeStart: start,
+		Site:          st.name,
+		PDC:           p.name,
+		MACAddress:    v.mac,
+	}
+	if m.v900 {
+		sess.Charge900V = 1
+	}
+
+	socStart := between(rng, 5, 55)
+	socTarget := between(rng, 70, 100)
+	peakKW := min(m.maxKW, p.maxKW) * between(rng, 0.85, 1.0)
+	meanKW := peakKW * between(rng, 0.55, 0.75)
+	duration := time.Duration(between(rng, 20, 180)) * time.Second
+
+	failed := rng.Float64() < baseFailureRate*m.risk/p.reliability
+	var f *failure
+	if failed {
+		weights := make([]float64, len(failures))
+		for i, fl := range failures {
+			weights[i] = fl.weight
+		}
+		f = &failures[weighted(rng, weights)]
+	}
+
+	switch {
+	case !failed && rng.Float64() < 0.02:
+		// Recharge quasi nulle (véhicule déjà plein, coupure côté véhicule)
+		energy := between(rng, 0.05, 0.95)
+		sess.SOCStart, sess.SOCEnd = ptr(round(socTarget, 0)), ptr(round(math.Min(100, socTarget+energy/m.batteryKWh*100), 0))
+		sess.EnergyKwh, sess.MeanPowerKw, sess.MaxPowerKw = ptr(round(energy, 2)), ptr(round(meanKW/4, 1)), ptr(round(peakKW/3, 1))
+		duration = time.Duration(60+rng.Intn(240)) * time.Second
+	case !failed || f.moment == "Charge" || f.moment == "Fin de charge":
+		socEnd := socTarget
+		if failed && f.moment == "Charge" {
+			socEnd = socStart + (socTarget-socStart)*between(rng, 0.05, 0.8)
+		}
+		energy := m.batteryKWh * (socEnd - socStart) / 100 * 1.05
+		sess.SOCStart, sess.SOCEnd = ptr(round(socStart, 0)), ptr(round(socEnd, 0))
+		sess.EnergyKwh, sess.MeanPowerKw, sess.MaxPowerKw = ptr(round(energy, 2)), ptr(round(meanKW, 1)), ptr(round(peakKW, 1))
+		duration += time.Duration(energy / meanKW * float64(time.Hour))
+	case f.moment != "Init":
+		// Le véhicule a communiqué son SOC avant l'échec
+		sess.SOCStart = ptr(round(socStart, 0))
+	}
+
+	if failed {
+		sess.StateOfCharge = 1
+		sess.Moment = f.moment
+		stp := f.steps[rng.Intn(len(f.steps))]
+		sess.MomentAvancee = stp.label
+		if rng.Float64() < f.evi {
+			sess.TypeErreur = "Erreur_EVI"
+			sess.EVIErrorCode = ptr(pick(rng, f.eviCodes))
+			sess.EVIMomentStep = ptr(stp.evi)
+		} else {
+			sess.TypeErreur = "Erreur_DownStream"
+			sess.DownstreamCodePC = ptr(pick(rng, f.dsCodes))
+		}
+	}
+
+	// Une session encore en cours n'a pas de fin
+	if end := start.Add(duration); end.Before(s.cfg.End) {
+		sess.DatetimeEnd = &end
+	}
+
+	s.sessions = append(s.sessions, sess)
+	return sess
+}
+
+func pick(rng *rand.Rand, codes []code) int {
+	weights := make([]float64, len(codes))
+	for i, c := range codes {
+		weights[i] = c.weight
+	}
+	return codes[weighted(rng, weights)].value
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,182 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/database"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// WriteCSV écrit chaque table dans <dir>/<table>.csv, avec les colonnes
+// attendues par la source CSV du serveur
+func (d *Dataset) WriteCSV(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tables := []func() error{
+		func() error {
+			return writeTable(dir, database.TableSessions, []string{
+				"ID", "Datetime start", "Datetime end", "Site", "PDC", "State of charge(0:good, 1:error)",
+				"type_erreur", "moment", "moment_avancee", "EVI Error Code", "EVI Status during error",
+				"Downstream Code PC", "Energy (Kwh)", "Mean Power (Kw)", "Max Power (Kw)",
+				"SOC Start", "SOC End", "MAC Address", "charge_900V",
+			}, d.Sessions, func(s models.Session) []string {
+				return []string{
+					s.ID, formatTime(s.DatetimeStart), formatTimePtr(s.DatetimeEnd), s.Site, s.PDC, strconv.Itoa(s.StateOfCharge),
+					s.TypeErreur, s.Moment, s.MomentAvancee, formatIntPtr(s.EVIErrorCode), formatIntPtr(s.EVIMomentStep),
+					formatIntPtr(s.DownstreamCodePC), formatFloatPtr(s.EnergyKwh), formatFloatPtr(s.MeanPowerKw), formatFloatPtr(s.MaxPowerKw),
+					formatFloatPtr(s.SOCStart), formatFloatPtr(s.SOCEnd), s.MACAddress, strconv.Itoa(s.Charge900V),
+				}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableAlertes, []string{
+				"Site", "PDC", "type_erreur", "detection", "occurrences_12h", "moment", "evi_code", "downstream_code_pc",
+			}, d.Alertes, func(a models.Alerte) []string {
+				return []string{
+					a.Site, a.PDC, a.TypeErreur, formatTime(a.Detection), strconv.Itoa(a.Occurrences12h), a.Moment,
+					formatIntPtr(a.EVICode), formatIntPtr(a.DownstreamCodePC),
+				}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableDefauts, []string{
+				"site", "date_debut", "date_fin", "defaut", "eqp",
+			}, d.Defauts, func(df models.Defaut) []string {
+				return []string{df.Site, formatTime(df.DateDebut), formatTimePtr(df.DateFin), df.Defaut, df.Equipement}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableSuspicious, []string{
+				"ID", "Site", "PDC", "MAC Address", "Vehicle", "Datetime start", "Datetime end",
+				"Energy (Kwh)", "SOC Start", "SOC End",
+			}, d.Suspicious, func(s models.SuspiciousTransaction) []string {
+				return []string{
+					s.ID, s.Site, s.PDC, s.MACAddress, s.Vehicle, formatTime(s.DatetimeStart), formatTimePtr(s.DatetimeEnd),
+					formatFloat(s.EnergyKwh), formatFloatPtr(s.SOCStart), formatFloatPtr(s.SOCEnd),
+				}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableMultiAttempts, []string{
+				"Site", "Heure", "MAC", "Vehicle", "tentatives", "PDC(s)", "1ère tentative",
+				"Dernière tentative", "ID(s)", "SOC start min", "SOC start max", "SOC end min", "SOC end max",
+			}, d.MultiAttempts, func(m models.MultiAttempt) []string {
+				return []string{
+					m.Site, m.Heure, m.MAC, m.Vehicle, strconv.Itoa(m.Tentatives), m.PDCs, formatTime(m.PremiereTentative),
+					formatTime(m.DerniereTentative), m.IDs, formatFloatPtr(m.SOCStartMin), formatFloatPtr(m.SOCStartMax),
+					formatFloatPtr(m.SOCEndMin), formatFloatPtr(m.SOCEndMax),
+				}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableChargesMAC, []string{
+				"ID", "Site", "MAC Address", "Vehicle", "Datetime start", "SOC Start", "SOC End", "is_ok",
+			}, d.ChargesMAC, func(c models.ChargeMAC) []string {
+				ok := "0"
+				if c.IsOK {
+					ok = "1"
+				}
+				return []string{
+					c.ID, c.Site, c.MACAddress, c.Vehicle, formatTime(c.DatetimeStart),
+					formatFloatPtr(c.SOCStart), formatFloatPtr(c.SOCEnd), ok,
+				}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableEvo, []string{"mois", "tr"}, d.StatsGlobal, func(s models.StatsGlobal) []string {
+				return []string{s.Mois, formatFloat(s.TauxReussite)}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableChargesDaily, []string{"Site", "day", "Status", "Nb"}, d.ChargesDaily, func(c models.ChargesDaily) []string {
+				return []string{c.Site, formatDay(c.Day), c.Status, strconv.Itoa(c.Nb)}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableDurationsSiteDaily, []string{"Site", "day", "dur_min"}, d.DurationsSiteDaily, func(r models.DurationsSiteDaily) []string {
+				return []string{r.Site, formatDay(r.Day), formatFloat(r.DurMin)}
+			})
+		},
+		func() error {
+			return writeTable(dir, database.TableDurationsPDCDaily, []string{"Site", "PDC", "day", "dur_min"}, d.DurationsPDCDaily, func(r models.DurationsPDCDaily) []string {
+				return []string{r.Site, r.PDC, formatDay(r.Day), formatFloat(r.DurMin)}
+			})
+		},
+	}
+
+	for _, write := range tables {
+		if err := write(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTable écrit une table en CSV séparé par des virgules
+func writeTable[T any](dir, table string, header []string, rows []T, record func(T) []string) error {
+	f, err := os.Create(filepath.Join(dir, table+".csv"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	w := csv.NewWriter(bw)
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+	for _, row := range rows {
+		if err := w.Write(record(row)); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("%s: %w", table, err)
+	}
+	return f.Close()
+}
+
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+func formatDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func formatIntPtr(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatFloatPtr(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return formatFloat(*f)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/export"
+	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/rollup"
+	"github.com/monitoring/charging-stations/internal/utils"
+)
+
+// ExportCSV envoie en CSV le jeu de données d'un onglet, avec les mêmes
+// filtres que les onglets
+func (h *Handler) ExportCSV(w http.ResponseWriter, r *http.Request) {
+	dataset := mux.Vars(r)["dataset"]
+	filters := h.parseFilters(r)
+	snap := h.db.Snapshot()
+	format := export.ParseFormat(r)
+	filename := export.Filename(dataset, filters.DateStart, filters.DateEnd, "csv")
+	ctx := r.Context()
+
+	var err error
+	switch dataset {
+	case "sessions":
+		var sessions columnar.View
+		if sessions, err = snap.FilterSessions(ctx, filters); err != nil {
+			break
+		}
+		err = export.WriteCSV(ctx, w, filename, format, export.SessionFields, viewRows(sessions))
+	case "alertes":
+		err = export.WriteCSV(ctx, w, filename, format, export.AlerteFields,
+			export.Slice(filterAlertes(snap.Alertes(), filters)))
+	case "defauts":
+		err = export.WriteCSV(ctx, w, filename, format, export.DefautFields,
+			export.Slice(filterDefauts(snap.Defauts(), filters)))
+	case "suspicious":
+		err = export.WriteCSV(ctx, w, filename, format, export.SuspiciousFields,
+			export.Slice(filterSuspiciousTransactions(snap.Suspicious(), filters)))
+	case "multi-attempts":
+		err = export.WriteCSV(ctx, w, filename, format, export.MultiAttemptFields,
+			export.Slice(filterMultiAttempts(snap.MultiAttempts(), filters)))
+	case "charges-mac":
+		err = export.WriteCSV(ctx, w, filename, format, export.ChargeMACFields,
+			export.Slice(filterChargesMAC(snap.ChargesMAC(), filters)))
+	case "site-stats":
+		var cells []rollup.Cell
+		if cells, err = snap.Cells(ctx, filters); err != nil {
+			break
+		}
+		err = export.WriteCSV(ctx, w, filename, format, export.SiteStatsFields,
+			export.Slice(utils.StatsBySiteFromCells(cells)))
+	case "pdc-stats":
+		var cells []rollup.Cell
+		if cells, err = snap.Cells(ctx, filters); err != nil {
+			break
+		}
+		err = export.WriteCSV(ctx, w, filename, format, export.PDCStatsFields,
+			export.Slice(export.PDCStatsRows(pdcStatsBySite(cells, r.FormValue("site")))))
+	case "code-occurrences":
+		var cells []rollup.Cell
+		if cells, err = snap.Cells(ctx, filters); err != nil {
+			break
+		}
+		err = export.WriteCSV(ctx, w, filename, format, export.CodeFields,
+			export.Slice(export.CodeRows(
+				utils.CodeOccurrencesFromCells(cells, true),
+				utils.CodeOccurrencesFromCells(cells, false))))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		log.Printf("⚠️  Export %s interrompu: %v", dataset, err)
+	}
+}
+
+// ExportXLSX envoie un classeur Excel du tableau de bord filtré : une
+// feuille par vue, calculée comme dans les onglets
+func (h *Handler) ExportXLSX(w http.ResponseWriter, r *http.Request) {
+	filters := h.parseFilters(r)
+	snap := h.db.Snapshot()
+	format := export.ParseFormat(r)
+
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
+
+	name := func(fr, en string) string {
+		if format.Lang == export.LangEN {
+			return en
+		}
+		return fr
+	}
+
+	wb := &export.Workbook{Lang: format.Lang}
+	export.AddSheet(wb, "KPIs", export.KPIFields, []models.KPISummary{utils.KPIsFromCells(cells)})
+	export.AddSheet(wb, "Sites", export.SiteStatsFields, utils.StatsBySiteFromCells(cells))
+	export.AddSheet(wb, "PDC", export.PDCStatsFields,
+		export.PDCStatsRows(pdcStatsBySite(cells, r.FormValue("site"))))
+	export.AddSheet(wb, "Moments", export.MomentCountFields, utils.MomentCountsFromCells(cells))
+	export.AddSheet(wb, name("Codes EVI", "EVI codes"), export.CodeFields,
+		export.CodeRows(utils.CodeOccurrencesFromCells(cells, true), nil))
+	export.AddSheet(wb, name("Codes Downstream", "Downstream codes"), export.CodeFields,
+		export.CodeRows(nil, utils.CodeOccurrencesFromCells(cells, false)))
+	export.AddSheet(wb, name("Alertes", "Alerts"), export.AlerteFields, filterAlertes(snap.Alertes(), filters))
+	export.AddSheet(wb, name("Défauts", "Defects"), export.DefautFields, filterDefauts(snap.Defauts(), filters))
+
+	filename := export.Filename("dashboard", filters.DateStart, filters.DateEnd, "xlsx")
+	if err := export.WriteXLSX(w, filename, wb); err != nil {
+		log.Printf("⚠️  Export XLSX interrompu: %v", err)
+	}
+}
+
+// viewRows parcourt les sessions d'une vue sans les copier
+func viewRows(v columnar.View) export.Rows[columnar.Row] {
+	return func(emit func(columnar.Row) error) error {
+		for it := v.Iter(); it.Next(); {
+			if err := emit(it.Row()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// pdcStatsBySite calcule les stats par PDC d'un site, ou de tous les sites
+// présents dans les cellules quand site est vide
+func pdcStatsBySite(cells []rollup.Cell, site string) map[string][]models.PDCStats {
+	sites := []string{site}
+	if site == "" {
+		seen := make(map[string]bool)
+		sites = nil
+		for _, c := range cells {
+			if !seen[c.Site] {
+				seen[c.Site] = true
+				sites = append(sites, c.Site)
+			}
+		}
+	}
+
+	bySite := make(map[string][]models.PDCStats, len(sites))
+	for _, s := range sites {
+		bySite[s] = utils.StatsByPDCFromCells(cells, s)
+	}
+	return bySite
+}
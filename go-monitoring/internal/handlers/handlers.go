@@ -8,13 +8,17 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/config"
 	"github.com/monitoring/charging-stations/internal/database"
 	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/table"
 	"github.com/monitoring/charging-stations/internal/utils"
 )
 
@@ -22,10 +26,14 @@ import (
 type Handler struct {
 	db        *database.DB
 	templates *template.Template
+	staticDir string
+	// started entre dans les ETag : un redémarrage (gabarits modifiés)
+	// invalide les copies des navigateurs
+	started time.Time
 }
 
 // New crée un nouveau handler
-func New(db *database.DB) *Handler {
+func New(db *database.DB, cfg config.Config) (*Handler, error) {
 	funcMap := template.FuncMap{
 		"sub": func(a, b int) int {
 			return a - b
@@ -33,12 +41,13 @@ func New(db *database.DB) *Handler {
 		"add": func(a, b int) int {
 			return a + b
 		},
-		"formatDate": func(t time.Time) string {
-			return t.Format("02/01/2006 15:04")
-		},
+		"formatDate": formatDate,
 		"formatDateShort": func(t time.Time) string {
 			return t.Format("02/01/2006")
 		},
+		"formatAge": formatAge,
+		"heat":      heatStyle,
+		"heatScale": heatScale,
 		"json": func(v interface{}) string {
 			b, err := json.Marshal(v)
 			if err != nil {
@@ -72,86 +81,116 @@ func New(db *database.DB) *Handler {
 		},
 	}
 
-	tmpl := template.Must(template.New("").Funcs(funcMap).ParseGlob("web/templates/*.html"))
+	tmpl, err := template.New("").Funcs(funcMap).ParseGlob(filepath.Join(cfg.Web.TemplatesDir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing templates: %w", err)
+	}
 
 	return &Handler{
 		db:        db,
 		templates: tmpl,
-	}
+		staticDir: cfg.Web.StaticDir,
+		started:   time.Now(),
+	}, nil
 }
 
 // RegisterRoutes enregistre toutes les routes
 func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.Use(Compress)
+
 	// Page principale
-	r.HandleFunc("/", h.Index).Methods("GET")
+	r.HandleFunc("/", h.conditional(h.Index)).Methods("GET")
 
-	// API pour les filtres
-	r.HandleFunc("/api/filters", h.GetFilters).Methods("POST")
-	r.HandleFunc("/api/kpis", h.GetKPIs).Methods("POST")
+	// API pour les filtres (GET pour la revalidation par ETag, POST conservé)
+	r.HandleFunc("/api/filters", h.conditional(h.GetFilters)).Methods("GET", "POST")
+	r.HandleFunc("/api/kpis", h.conditional(h.GetKPIs)).Methods("GET", "POST")
 
 	// Tabs
-	r.HandleFunc("/tabs/overview", h.TabOverview).Methods("POST")
-	r.HandleFunc("/tabs/general", h.TabGeneral).Methods("POST")
-	r.HandleFunc("/tabs/comparison", h.TabComparison).Methods("POST")
-	r.HandleFunc("/tabs/pdc-details", h.TabPDCDetails).Methods("POST")
-	r.HandleFunc("/tabs/stats", h.TabStats).Methods("POST")
-	r.HandleFunc("/tabs/projection", h.TabProjection).Methods("POST")
-	r.HandleFunc("/tabs/attempts", h.TabAttempts).Methods("POST")
-	r.HandleFunc("/tabs/suspicious", h.TabSuspicious).Methods("POST")
-	r.HandleFunc("/tabs/error-moment", h.TabErrorMoment).Methods("POST")
-	r.HandleFunc("/tabs/error-specific", h.TabErrorSpecific).Methods("POST")
-	r.HandleFunc("/tabs/alerts", h.TabAlerts).Methods("POST")
-	r.HandleFunc("/tabs/evolution", h.TabEvolution).Methods("POST")
-	r.HandleFunc("/tabs/defects", h.TabDefects).Methods("POST")
+	r.HandleFunc("/tabs/overview", h.conditional(h.TabOverview)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/general", h.conditional(h.TabGeneral)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/comparison", h.conditional(h.TabComparison)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/pdc-details", h.conditional(h.TabPDCDetails)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/stats", h.conditional(h.TabStats)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/projection", h.conditional(h.TabProjection)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/attempts", h.conditional(h.TabAttempts)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/suspicious", h.conditional(h.TabSuspicious)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/error-moment", h.conditional(h.TabErrorMoment)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/error-specific", h.conditional(h.TabErrorSpecific)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/alerts", h.conditional(h.TabAlerts)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/evolution", h.conditional(h.TabEvolution)).Methods("GET", "POST")
+	r.HandleFunc("/tabs/defects", h.conditional(h.TabDefects)).Methods("GET", "POST")
+
+	// Exports CSV et classeur Excel
+	r.HandleFunc("/export/{dataset}.csv", h.conditional(h.ExportCSV)).Methods("GET", "POST")
+	r.HandleFunc("/export/dashboard.xlsx", h.conditional(h.ExportXLSX)).Methods("GET", "POST")
 
 	// Refresh cache
 	r.HandleFunc("/api/refresh-cache", h.RefreshCache).Methods("POST")
+	r.HandleFunc("/api/cache-status", h.CacheStatus).Methods("GET")
+	r.HandleFunc("/healthz", h.Healthz).Methods("GET")
+	r.HandleFunc("/partials/cache-banner", h.CacheBanner).Methods("GET")
 
 	// Static files
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("web/static"))))
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(h.staticDir))))
 }
 
 // Index affiche la page principale
 func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
-	sessions := h.db.GetSessions()
-	sites := utils.GetUniqueSites(sessions)
+	index := h.db.Snapshot().SessionIndex()
+
+	// Les bases ne sont proposées en filtre que si plusieurs sont fédérées
+	var sources []string
+	if names := h.db.Sources(); len(names) > 1 {
+		sources = names
+	}
 
 	data := struct {
-		Sites []string
-		Year  int
-		Month int
+		Sites       []string
+		SiteSources map[string]string
+		Sources     []string
+		Year        int
+		Month       int
 	}{
-		Sites: sites,
-		Year:  time.Now().Year(),
-		Month: int(time.Now().Month()),
+		Sites:       index.Sites(),
+		SiteSources: index.SiteSources(),
+		Sources:     sources,
+		Year:        time.Now().Year(),
+		Month:       int(time.Now().Month()),
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "index.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "index.html", data)
 }
 
 // GetFilters récupère et filtre les sessions
 func (h *Handler) GetFilters(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := h.db.GetSessions()
-	filtered := utils.FilterSessions(sessions, filters)
+	cells, err := h.db.Snapshot().Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
+
+	total := 0
+	for _, c := range cells {
+		total += c.Total()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"total": len(filtered),
+		"total": total,
 	})
 }
 
 // GetKPIs calcule et retourne les KPIs
 func (h *Handler) GetKPIs(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := h.db.GetSessions()
-	filtered := utils.FilterSessions(sessions, filters)
-
-	kpis := utils.CalculateKPIs(filtered, filters)
+	snap := h.db.Snapshot()
+	kpis, err := database.Memoize(snap, "kpis", filters, func() (models.KPISummary, error) {
+		cells, err := snap.Cells(r.Context(), filters)
+		return utils.KPIsFromCells(cells), err
+	})
+	if err != nil {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(kpis)
@@ -160,16 +199,20 @@ func (h *Handler) GetKPIs(w http.ResponseWriter, r *http.Request) {
 // TabOverview retourne l'onglet vue d'ensemble
 func (h *Handler) TabOverview(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
+	snap := h.db.Snapshot()
 
 	// Récupérer les données
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
-	defauts := utils.GetActiveDefauts(h.db.GetDefauts(), filters)
-	suspicious := filterSuspiciousTransactions(h.db.GetSuspicious(), filters)
-	multiAttempts := filterMultiAttempts(h.db.GetMultiAttempts(), filters)
-	alertes := filterAlertes(h.db.GetAlertes(), filters)
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
+	defauts := utils.GetActiveDefauts(snap.Defauts(), filters)
+	suspicious := filterSuspiciousTransactions(snap.Suspicious(), filters)
+	multiAttempts := filterMultiAttempts(snap.MultiAttempts(), filters)
+	alertes := filterAlertes(snap.Alertes(), filters)
 
-	kpis := utils.CalculateKPIs(sessions, filters)
-	siteStats := utils.GetTop10Sites(sessions)
+	kpis := utils.KPIsFromCells(cells)
+	siteStats := utils.Top10SitesFromCells(cells)
 
 	data := struct {
 		KPIs          models.KPISummary
@@ -181,27 +224,27 @@ func (h *Handler) TabOverview(w http.ResponseWriter, r *http.Request) {
 	}{
 		KPIs:          kpis,
 		Defauts:       defauts,
-		Suspicious:    suspFiltered,
+		Suspicious:    suspicious,
 		MultiAttempts: multiAttempts,
 		Alertes:       alertes,
 		TopSites:      siteStats,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_overview.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_overview.html", data)
 }
 
 // TabGeneral retourne l'onglet général
 func (h *Handler) TabGeneral(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
+	snap := h.db.Snapshot()
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
 
-	kpis := utils.CalculateKPIs(sessions, filters)
-	siteStats := utils.GetStatsBySite(sessions)
-	momentCounts := utils.GetMomentCounts(sessions)
+	kpis := utils.KPIsFromCells(cells)
+	siteStats := utils.StatsBySiteFromCells(cells)
+	momentCounts := utils.MomentCountsFromCells(cells)
 
 	data := struct {
 		KPIs         models.KPISummary
@@ -213,41 +256,80 @@ func (h *Handler) TabGeneral(w http.ResponseWriter, r *http.Request) {
 		MomentCounts: momentCounts,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_general.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_general.html", data)
 }
 
 // TabComparison retourne l'onglet comparaison par site
 func (h *Handler) TabComparison(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
+	snap := h.db.Snapshot()
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
+
+	siteStats := utils.StatsBySiteFromCells(cells)
+
+	hourly, err := database.Memoize(snap, "hourly", filters, func() (models.HourlyAnalysis, error) {
+		sessions, err := snap.FilterSessions(r.Context(), filters)
+		if err != nil {
+			return models.HourlyAnalysis{}, err
+		}
+		return utils.HourlyFromView(r.Context(), sessions)
+	})
+	if err != nil {
+		return
+	}
 
-	siteStats := utils.GetStatsBySite(sessions)
+	// Zoom horaire sur un site (le premier par défaut)
+	var focus *models.SiteHourly
+	for i := range hourly.Sites {
+		if hourly.Sites[i].Site == r.FormValue("hour_site") {
+			focus = &hourly.Sites[i]
+		}
+	}
+	if focus == nil && len(hourly.Sites) > 0 {
+		focus = &hourly.Sites[0]
+	}
 
 	data := struct {
 		SiteStats []models.SiteStats
+		Hourly    models.HourlyAnalysis
+		Hours     []string
+		Focus     *models.SiteHourly
+		FocusURL  string
 	}{
 		SiteStats: siteStats,
+		Hourly:    hourly,
+		Hours:     hourLabels,
+		Focus:     focus,
+		FocusURL:  withParam(r, "hour_site", ""),
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_comparison.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_comparison.html", data)
 }
 
+// hourLabels sont les libellés des heures de la journée, de 00:00 à 23:00
+var hourLabels = func() []string {
+	labels := make([]string, 24)
+	for h := range labels {
+		labels[h] = fmt.Sprintf("%02d:00", h)
+	}
+	return labels
+}()
+
 // TabPDCDetails retourne l'onglet détails PDC
 func (h *Handler) TabPDCDetails(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
+	snap := h.db.Snapshot()
 	site := r.FormValue("site")
 
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
-	pdcStats := utils.GetStatsByPDC(sessions, site)
-	momentCounts := utils.GetMomentCounts(sessions)
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
+	pdcStats := utils.StatsByPDCFromCells(cells, site)
+	momentCounts := utils.MomentCountsFromCells(cells)
 
 	data := struct {
 		Site         string
@@ -259,97 +341,173 @@ func (h *Handler) TabPDCDetails(w http.ResponseWriter, r *http.Request) {
 		MomentCounts: momentCounts,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_pdc_details.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_pdc_details.html", data)
 }
 
+// defaultVehicleMin est le nombre minimal de charges d'un véhicule pour
+// figurer au classement des taux de réussite
+const defaultVehicleMin = 20
+
 // TabStats retourne l'onglet statistiques
 func (h *Handler) TabStats(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
+	snap := h.db.Snapshot()
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
+	stats, err := database.Memoize(snap, "session-stats", filters, func() (models.SessionStats, error) {
+		sessions, err := snap.FilterSessions(r.Context(), filters)
+		if err != nil {
+			return models.SessionStats{}, err
+		}
+		return utils.SessionStatsFromView(r.Context(), sessions)
+	})
+	if err != nil {
+		return
+	}
+
+	// Taux de réussite par véhicule, classement limité aux véhicules assez
+	// fréquents
+	vehicleMin := defaultVehicleMin
+	if n, err := strconv.Atoi(r.FormValue("vehicle_min")); err == nil && n > 0 {
+		vehicleMin = n
+	}
+	vehicles := utils.VehicleStatsFromCharges(filterChargesMAC(snap.ChargesMAC(), filters))
+
+	// Durées de fonctionnement, détail par PDC pour un site (le plus utilisé
+	// par défaut)
+	operating := utils.OperatingTimeFrom(snap.DurationsSiteDaily(), snap.DurationsPDCDaily(), filters)
+	durationSite := r.FormValue("duration_site")
+	known := false
+	for _, st := range operating.Sites {
+		known = known || st.Site == durationSite
+	}
+	if !known && len(operating.Sites) > 0 {
+		durationSite = operating.Sites[0].Site
+	}
+	var durationPDCs []models.OperatingHours
+	for _, p := range operating.PDCs {
+		if p.Site == durationSite {
+			durationPDCs = append(durationPDCs, p)
+		}
+	}
 
 	// Calculs statistiques
-	kpis := utils.CalculateKPIs(sessions, filters)
+	kpis := utils.KPIsFromCells(cells)
 
 	data := struct {
-		KPIs models.KPISummary
+		KPIs          models.KPISummary
+		Stats         models.SessionStats
+		Vehicles      []models.VehicleStats
+		VehicleRank   []models.VehicleStats
+		VehicleMin    int
+		VehicleMinURL string
+		Operating     models.OperatingTime
+		DurationSite  string
+		DurationPDCs  []models.OperatingHours
+		DurationURL   string
 	}{
-		KPIs: kpis,
-	}
-
-	if err := h.templates.ExecuteTemplate(w, "tab_stats.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+		KPIs:          kpis,
+		Stats:         stats,
+		Vehicles:      vehicles,
+		VehicleRank:   utils.RankVehicles(vehicles, vehicleMin),
+		VehicleMin:    vehicleMin,
+		VehicleMinURL: withParam(r, "vehicle_min", ""),
+		Operating:     operating,
+		DurationSite:  durationSite,
+		DurationPDCs:  durationPDCs,
+		DurationURL:   withParam(r, "duration_site", ""),
+	}
+
+	h.render(w, r, "tab_stats.html", data)
 }
 
-// TabProjection retourne l'onglet projection pivot
+// TabProjection retourne l'onglet projection pivot : erreurs par site (ou
+// par PDC avec rows=pdc) × moment × code, EVI et DownStream séparés
 func (h *Handler) TabProjection(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
+	snap := h.db.Snapshot()
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
+
+	byPDC := r.FormValue("rows") == "pdc"
 
-	// Logique de pivot sera implémentée ici
 	data := struct {
-		Sessions []models.Session
+		ByPDC   bool
+		SiteURL string
+		PDCURL  string
+		Pivots  []pivotView
 	}{
-		Sessions: sessions,
+		ByPDC:   byPDC,
+		SiteURL: withParam(r, "rows", "site"),
+		PDCURL:  withParam(r, "rows", "pdc"),
+		Pivots: []pivotView{
+			{Title: "Codes EVI", ByPDC: byPDC, Pivot: utils.CodePivotFromCells(cells, true, byPDC)},
+			{Title: "Codes DownStream", ByPDC: byPDC, Pivot: utils.CodePivotFromCells(cells, false, byPDC)},
+		},
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_projection.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_projection.html", data)
+}
+
+// pivotView est un tableau pivot de l'onglet projection
+type pivotView struct {
+	Title string
+	ByPDC bool
+	Pivot models.CodePivot
 }
 
 // TabAttempts retourne l'onglet tentatives multiples
 func (h *Handler) TabAttempts(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	filtered := filterMultiAttempts(h.db.GetMultiAttempts(), filters)
+	snap := h.db.Snapshot()
+	filtered := filterMultiAttempts(snap.MultiAttempts(), filters)
+
+	page := table.Build(r, "attempts-table", "Aucune tentative multiple trouvée.",
+		filtered, attemptsColumns, table.ParseParams(r))
 
 	data := struct {
-		MultiAttempts []models.MultiAttempt
+		Table table.Page
 	}{
-		MultiAttempts: filtered,
+		Table: page,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_attempts.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.renderTable(w, r, "tab_attempts.html", data, page)
 }
 
 // TabSuspicious retourne l'onglet transactions suspectes
 func (h *Handler) TabSuspicious(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	filtered := filterSuspiciousTransactions(h.db.GetSuspicious(), filters)
+	snap := h.db.Snapshot()
+	filtered := filterSuspiciousTransactions(snap.Suspicious(), filters)
+
+	page := table.Build(r, "suspicious-table", "Aucune transaction suspecte détectée.",
+		filtered, suspiciousColumns, table.ParseParams(r))
 
 	data := struct {
-		Suspicious []models.SuspiciousTransaction
+		Table table.Page
 	}{
-		Suspicious: filtered,
+		Table: page,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_suspicious.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.renderTable(w, r, "tab_suspicious.html", data, page)
 }
 
 // TabErrorMoment retourne l'onglet erreur moment
 func (h *Handler) TabErrorMoment(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
+	snap := h.db.Snapshot()
+	cells, err := snap.Cells(r.Context(), filters)
+	if err != nil {
+		return
+	}
 
-	momentCounts := utils.GetMomentCounts(sessions)
-	eviOccurrences := utils.GetCodeOccurrences(sessions, true)
-	dsOccurrences := utils.GetCodeOccurrences(sessions, false)
+	momentCounts := utils.MomentCountsFromCells(cells)
+	eviOccurrences := utils.CodeOccurrencesFromCells(cells, true)
+	dsOccurrences := utils.CodeOccurrencesFromCells(cells, false)
 
 	data := struct {
 		MomentCounts   []models.MomentCount
@@ -361,26 +519,28 @@ func (h *Handler) TabErrorMoment(w http.ResponseWriter, r *http.Request) {
 		DSOccurrences:  dsOccurrences,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_error_moment.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_error_moment.html", data)
 }
 
 // TabErrorSpecific retourne l'onglet erreur spécifique
 func (h *Handler) TabErrorSpecific(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	sessions := utils.FilterSessions(h.db.GetSessions(), filters)
+	snap := h.db.Snapshot()
+	sessions, err := snap.FilterSessions(r.Context(), filters)
+	if err != nil {
+		return
+	}
 
 	// Filtres spécifiques pour MAC et codes
 	macFilter := r.FormValue("mac")
 	codeFilter := r.FormValue("code")
 
-	var filtered []models.Session
-	for _, s := range sessions {
+	var filtered []columnar.Row
+	for it := sessions.Iter(); it.Next(); {
+		s := it.Row()
+
 		// Filtre MAC
-		if macFilter != "" && !strings.Contains(strings.ToLower(s.MACAddress), strings.ToLower(macFilter)) {
+		if macFilter != "" && !strings.Contains(strings.ToLower(s.MACAddress()), strings.ToLower(macFilter)) {
 			continue
 		}
 
@@ -388,8 +548,8 @@ func (h *Handler) TabErrorSpecific(w http.ResponseWriter, r *http.Request) {
 		if codeFilter != "" {
 			code, err := strconv.Atoi(codeFilter)
 			if err == nil {
-				if s.EVIErrorCode == nil || *s.EVIErrorCode != code {
-					if s.DownstreamCodePC == nil || *s.DownstreamCodePC != code {
+				if evi, ok := s.EVIErrorCode(); !ok || evi != code {
+					if ds, ok := s.DownstreamCodePC(); !ok || ds != code {
 						continue
 					}
 				}
@@ -399,23 +559,23 @@ func (h *Handler) TabErrorSpecific(w http.ResponseWriter, r *http.Request) {
 		filtered = append(filtered, s)
 	}
 
+	page := table.Build(r, "error-specific-table", "Aucune session correspondant aux filtres.",
+		filtered, errorSpecificColumns, table.ParseParams(r))
+
 	data := struct {
-		Sessions []models.Session
+		Table table.Page
 	}{
-		Sessions: filtered,
+		Table: page,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_error_specific.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.renderTable(w, r, "tab_error_specific.html", data, page)
 }
 
 // TabAlerts retourne l'onglet alertes
 func (h *Handler) TabAlerts(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	filtered := filterAlertes(h.db.GetAlertes(), filters)
+	snap := h.db.Snapshot()
+	filtered := filterAlertes(snap.Alertes(), filters)
 
 	data := struct {
 		Alertes []models.Alerte
@@ -423,16 +583,13 @@ func (h *Handler) TabAlerts(w http.ResponseWriter, r *http.Request) {
 		Alertes: filtered,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_alerts.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_alerts.html", data)
 }
 
 // TabEvolution retourne l'onglet évolution
 func (h *Handler) TabEvolution(w http.ResponseWriter, r *http.Request) {
-	stats := h.db.GetStatsGlobal()
+	snap := h.db.Snapshot()
+	stats := snap.StatsGlobal()
 
 	data := struct {
 		Stats []models.StatsGlobal
@@ -440,17 +597,14 @@ func (h *Handler) TabEvolution(w http.ResponseWriter, r *http.Request) {
 		Stats: stats,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_evolution.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_evolution.html", data)
 }
 
 // TabDefects retourne l'onglet historique défauts
 func (h *Handler) TabDefects(w http.ResponseWriter, r *http.Request) {
 	filters := h.parseFilters(r)
-	filtered := filterDefauts(h.db.GetDefauts(), filters)
+	snap := h.db.Snapshot()
+	filtered := filterDefauts(snap.Defauts(), filters)
 
 	data := struct {
 		Defauts []models.Defaut
@@ -458,27 +612,50 @@ func (h *Handler) TabDefects(w http.ResponseWriter, r *http.Request) {
 		Defauts: filtered,
 	}
 
-	if err := h.templates.ExecuteTemplate(w, "tab_defects.html", data); err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
+	h.render(w, r, "tab_defects.html", data)
 }
 
-// RefreshCache force le refresh du cache
+// RefreshCache force le refresh du cache, ou d'une seule base avec ?source=
 func (h *Handler) RefreshCache(w http.ResponseWriter, r *http.Request) {
-	if err := h.db.RefreshCache(); err != nil {
-		http.Error(w, fmt.Sprintf("Error refreshing cache: %v", err), http.StatusInternalServerError)
-		return
+	var report database.RefreshReport
+	var err error
+	if source := r.FormValue("source"); source != "" {
+		report, err = h.db.RefreshSource(r.Context(), source)
+	} else {
+		report, err = h.db.RefreshCache(r.Context())
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "error",
+			"message": fmt.Sprintf("Error refreshing cache: %v", err),
+			"report":  report,
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "success",
 		"message": "Cache refreshed successfully",
+		"report":  report,
 	})
 }
 
+// render exécute un template, sauf si le client a abandonné la requête
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	if r.Context().Err() != nil {
+		return
+	}
+
+	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
 // parseFilters parse les filtres depuis la requête
 func (h *Handler) parseFilters(r *http.Request) models.Filters {
 	// Préserver le corps pour pouvoir tenter plusieurs formats (form-data puis JSON)
@@ -506,6 +683,11 @@ func (h *Handler) parseFilters(r *http.Request) models.Filters {
 		filters.Sites = sites
 	}
 
+	// Bases d'origine (fédération)
+	if sources := r.Form["sources[]"]; len(sources) > 0 {
+		filters.Sources = sources
+	}
+
 	// Date mode
 	filters.DateMode = r.FormValue("date_mode")
 	if filters.DateMode == "" {
@@ -568,6 +750,9 @@ func filterSuspiciousTransactions(transactions []models.SuspiciousTransaction, f
 		if !matchesSite(filters.Sites, s.Site) {
 			continue
 		}
+		if !matchesSite(filters.Sources, s.Source) {
+			continue
+		}
 
 		if !withinRange(s.DatetimeStart, filters.DateStart, filters.DateEnd) {
 			continue
@@ -586,6 +771,9 @@ func filterMultiAttempts(attempts []models.MultiAttempt, filters models.Filters)
 		if !matchesSite(filters.Sites, m.Site) {
 			continue
 		}
+		if !matchesSite(filters.Sources, m.Source) {
+			continue
+		}
 
 		if !intervalOverlaps(m.PremiereTentative, m.DerniereTentative, filters.DateStart, filters.DateEnd) {
 			continue
@@ -604,6 +792,9 @@ func filterAlertes(alertes []models.Alerte, filters models.Filters) []models.Ale
 		if !matchesSite(filters.Sites, a.Site) {
 			continue
 		}
+		if !matchesSite(filters.Sources, a.Source) {
+			continue
+		}
 
 		if len(filters.TypesErreur) > 0 && !containsString(filters.TypesErreur, a.TypeErreur) {
 			continue
@@ -630,6 +821,9 @@ func filterDefauts(defauts []models.Defaut, filters models.Filters) []models.Def
 		if !matchesSite(filters.Sites, d.Site) {
 			continue
 		}
+		if !matchesSite(filters.Sources, d.Source) {
+			continue
+		}
 
 		if !withinRange(d.DateDebut, filters.DateStart, filters.DateEnd) {
 			continue
@@ -641,6 +835,29 @@ func filterDefauts(defauts []models.Defaut, filters models.Filters) []models.Def
 	return filtered
 }
 
+func filterChargesMAC(charges []models.ChargeMAC, filters models.Filters) []models.ChargeMAC {
+	var filtered []models.ChargeMAC
+
+	for _, c := range charges {
+		if !matchesSite(filters.Sites, c.Site) {
+			continue
+		}
+		if !matchesSite(filters.Sources, c.Source) {
+			continue
+		}
+
+		if !withinRange(c.DatetimeStart, filters.DateStart, filters.DateEnd) {
+			continue
+		}
+
+		filtered = append(filtered, c)
+	}
+
+	return filtered
+}
+
+// matchesSite vérifie qu'une valeur fait partie de la sélection (vide = tout) ;
+// sert aussi pour les bases d'origine
 func matchesSite(selected []string, site string) bool {
 	if len(selected) == 0 {
 		return true
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Compress compresse les réponses en brotli ou gzip selon l'en-tête
+// Accept-Encoding du client. Les réponses déjà encodées, partielles ou
+// sans corps, et les types non textuels (images...) sont transmis tels
+// quels.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding choisit brotli, sinon gzip, parmi les encodages
+// acceptés (q > 0) ; "" si aucun ne convient
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+
+	for _, encoding := range []string{"br", "gzip"} {
+		if ok, listed := accepted[encoding]; ok || (!listed && accepted["*"]) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+var (
+	gzipWriters   = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, 5) }}
+)
+
+// encoder est un gzip.Writer ou un brotli.Writer
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressWriter décide à l'envoi des en-têtes s'il compresse la réponse
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	enc         encoder
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	if compressible(status, h) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		if cw.encoding == "br" {
+			cw.enc = brotliWriters.Get().(encoder)
+		} else {
+			cw.enc = gzipWriters.Get().(encoder)
+		}
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.enc == nil {
+		return cw.ResponseWriter.Write(b)
+	}
+	return cw.enc.Write(b)
+}
+
+// Flush vide l'encodeur puis la connexion (réponses envoyées au fil de
+// l'eau)
+func (cw *compressWriter) Flush() {
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack laisse passer les connexions détournées (websockets)
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking not supported")
+}
+
+// Close termine le flux compressé et rend l'encodeur à son pool
+func (cw *compressWriter) Close() error {
+	if cw.enc == nil {
+		return nil
+	}
+	err := cw.enc.Close()
+	cw.enc.Reset(io.Discard)
+	if cw.encoding == "br" {
+		brotliWriters.Put(cw.enc)
+	} else {
+		gzipWriters.Put(cw.enc)
+	}
+	cw.enc = nil
+	return err
+}
+
+// compressible indique si une réponse gagne à être compressée
+func compressible(status int, h http.Header) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	ct := h.Get("Content-Type")
+	// Les classeurs XLSX sont déjà des archives zip
+	if strings.Contains(ct, "openxmlformats") || strings.Contains(ct, "zip") {
+		return false
+	}
+	return strings.HasPrefix(ct, "text/") ||
+		strings.Contains(ct, "json") ||
+		strings.Contains(ct, "javascript") ||
+		strings.Contains(ct, "xml") ||
+		strings.Contains(ct, "svg")
+}
+
+// conditional ajoute ETag et Last-Modified aux réponses qui ne dépendent
+// que de l'instantané du cache et des paramètres de la requête, et répond
+// 304 aux requêtes GET dont la copie du client est encore valide. L'ETag
+// combine la version et la date du cache, le chemin, les paramètres
+// triés, le fragment demandé par HTMX, le jour courant (les filtres par
+// défaut en dépendent) et le démarrage du serveur (les gabarits peuvent
+// avoir changé).
+func (h *Handler) conditional(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snap := h.db.Snapshot()
+		lastUpdate := snap.LastUpdate().UTC().Truncate(time.Second)
+
+		modified := lastModified(lastUpdate, h.started, time.Now())
+		if !modified.IsZero() {
+			w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		}
+		// Le navigateur garde la réponse mais la revalide à chaque fois
+		w.Header().Set("Cache-Control", "no-cache")
+
+		// Les paramètres d'un POST sont dans le corps : seul un GET peut être
+		// revalidé
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		etag := h.etag(r, snap.Version(), lastUpdate)
+		w.Header().Set("ETag", etag)
+		// Un rechargement HTMX ne rend qu'un fragment de l'onglet
+		w.Header().Add("Vary", "HX-Target")
+
+		if notModified(r, etag, modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) etag(r *http.Request, version uint64, lastUpdate time.Time) string {
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d|%d|%d|%s|%s|%s", h.started.UnixNano(), version, lastUpdate.Unix(), time.Now().Format("2006-01-02"), r.URL.Path, r.Header.Get("HX-Target"))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		fmt.Fprintf(hash, "|%s=%q", k, values)
+	}
+
+	// Faible : la même réponse peut être servie compressée ou non
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// lastModified date une réponse du plus récent du dernier refresh, du
+// démarrage du serveur (gabarits) et du début du jour courant (les filtres
+// par défaut en dépendent, une réponse de la veille est périmée). Un
+// instantané restauré au démarrage garde sa date de refresh ; sans
+// instantané, la réponse n'est pas datée.
+func lastModified(lastUpdate, started, now time.Time) time.Time {
+	if lastUpdate.IsZero() {
+		return time.Time{}
+	}
+
+	modified := lastUpdate
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for _, t := range []time.Time{started, today} {
+		if t = t.UTC().Truncate(time.Second); t.After(modified) {
+			modified = t
+		}
+	}
+	return modified
+}
+
+// notModified évalue If-None-Match, ou à défaut If-Modified-Since
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Sans paramètres, la date de modification suffit à dater la réponse
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && r.URL.RawQuery == "" && !modified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !modified.After(t)
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLastModified(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2024, time.March, d, h, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name                     string
+		lastUpdate, started, now time.Time
+		want                     time.Time
+	}{
+		{"dernier refresh", day(1, 10), day(1, 8), day(1, 12), day(1, 10)},
+		{"démarrage après le refresh", day(1, 10), day(1, 11), day(1, 12), day(1, 11)},
+		// Le jour a changé depuis le dernier refresh : les filtres par
+		// défaut ne couvrent plus la même période
+		{"changement de jour", day(1, 23), day(1, 8), day(2, 0).Add(5 * time.Minute), day(2, 0)},
+		{"sans instantané", time.Time{}, day(1, 8), day(1, 12), time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := lastModified(tt.lastUpdate, tt.started, tt.now); !got.Equal(tt.want) {
+			t.Errorf("%s: lastModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)
+	etag := `W/"abc"`
+
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		want   bool
+	}{
+		{"ETag identique", "/tab/general?site=A", map[string]string{"If-None-Match": `"abc"`}, true},
+		{"ETag parmi d'autres", "/tab/general", map[string]string{"If-None-Match": `W/"x", W/"abc"`}, true},
+		{"ETag différent, date valide", "/tab/general", map[string]string{"If-None-Match": `W/"x"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, false},
+		{"date valide sans paramètres", "/tab/general", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, true},
+		// Copie de la veille : périmée après le changement de jour
+		{"date de la veille", "/tab/general", map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, false},
+		{"date avec paramètres", "/tab/general?site=A", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, false},
+		{"sans en-tête", "/tab/general", nil, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		if got := notModified(r, etag, modified); got != tt.want {
+			t.Errorf("%s: notModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
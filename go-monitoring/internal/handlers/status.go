@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/database"
+	"github.com/monitoring/charging-stations/internal/memo"
+)
+
+// cacheStatus décrit l'état du cache exposé en JSON et dans le bandeau
+type cacheStatus struct {
+	Version    uint64                  `json:"version"`
+	LastUpdate time.Time               `json:"last_update"`
+	RestoredAt time.Time               `json:"restored_at"`
+	Stale      bool                    `json:"stale"`
+	Health     database.Health         `json:"health"`
+	Failed     []database.TableReport  `json:"failed,omitempty"`
+	Rejected   int                     `json:"rejected"`
+	Report     *database.RefreshReport `json:"report,omitempty"`
+	Memo       memo.Stats              `json:"memo"`
+	Memory     *memoryStatus           `json:"memory,omitempty"`
+}
+
+// memoryStatus décrit la mémoire occupée par les sessions du cache et par
+// le processus
+type memoryStatus struct {
+	Sessions  columnar.Footprint `json:"sessions"`
+	HeapAlloc uint64             `json:"heap_alloc"`
+	HeapSys   uint64             `json:"heap_sys"`
+	NumGC     uint32             `json:"num_gc"`
+}
+
+// Loading indique qu'aucun refresh n'a encore abouti
+func (s cacheStatus) Loading() bool {
+	return s.Report == nil
+}
+
+// Restored indique que les données servies proviennent de la sauvegarde
+// disque, en attendant la fin du premier refresh
+func (s cacheStatus) Restored() bool {
+	return !s.RestoredAt.IsZero()
+}
+
+// OldestSuccess retourne la date du plus ancien chargement réussi
+func (s cacheStatus) OldestSuccess() time.Time {
+	if s.Report == nil {
+		return time.Time{}
+	}
+	return s.Report.OldestSuccess()
+}
+
+func (h *Handler) cacheStatus() cacheStatus {
+	snap := h.db.Snapshot()
+	report := h.db.LastReport()
+
+	status := cacheStatus{
+		Version:    snap.Version(),
+		LastUpdate: snap.LastUpdate(),
+		RestoredAt: snap.RestoredAt(),
+		Health:     h.db.Health(),
+		Report:     report,
+		Memo:       h.db.MemoStats(),
+	}
+	status.Stale = status.Health.State == database.StateStale
+	if report != nil {
+		status.Failed = report.Failed()
+		status.Rejected = report.Rejected()
+	}
+
+	return status
+}
+
+// CacheStatus retourne l'état du cache, le rapport du dernier refresh et
+// l'occupation mémoire
+func (h *Handler) CacheStatus(w http.ResponseWriter, r *http.Request) {
+	status := h.cacheStatus()
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	status.Memory = &memoryStatus{
+		Sessions:  h.db.Snapshot().Footprint(),
+		HeapAlloc: ms.HeapAlloc,
+		HeapSys:   ms.HeapSys,
+		NumGC:     ms.NumGC,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Healthz expose l'état du tableau de bord aux health checks : 200 tant
+// que des données récentes sont servies (healthy, degraded), 503 sinon
+// (connecting, stale)
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	health := h.db.Health()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch health.State {
+	case database.StateHealthy, database.StateDegraded:
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+// CacheBanner retourne le bandeau d'alerte (périmé / tables en échec)
+func (h *Handler) CacheBanner(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "cache_banner.html", h.cacheStatus())
+}
+
+// formatAge affiche l'âge d'une date de façon lisible ("il y a 3 h")
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "jamais"
+	}
+
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "à l'instant"
+	case d < time.Hour:
+		return fmt.Sprintf("il y a %d min", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("il y a %d h", int(d.Hours()))
+	default:
+		return fmt.Sprintf("il y a %d j", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/table"
+)
+
+// Colonnes des tableaux paginés des onglets
+
+var errorSpecificColumns = []table.Column[columnar.Row]{
+	{Key: "site", Label: "Site", Text: columnar.Row.Site},
+	{Key: "pdc", Label: "PDC", Text: columnar.Row.PDC},
+	{Key: "mac", Label: "MAC", Text: columnar.Row.MACAddress},
+	{Key: "type", Label: "Type", Text: columnar.Row.TypeErreur},
+	{Key: "evi", Label: "Code EVI", Text: optionalText(columnar.Row.EVIErrorCode), Value: optionalValue(columnar.Row.EVIErrorCode)},
+	{Key: "ds", Label: "Code DownStream", Text: optionalText(columnar.Row.DownstreamCodePC), Value: optionalValue(columnar.Row.DownstreamCodePC)},
+	{Key: "start", Label: "Début", Text: sessionStartText, Value: sessionStart},
+}
+
+var suspiciousColumns = []table.Column[models.SuspiciousTransaction]{
+	{Key: "site", Label: "Site", Text: func(s models.SuspiciousTransaction) string { return s.Site }},
+	{Key: "pdc", Label: "PDC", Text: func(s models.SuspiciousTransaction) string { return s.PDC }},
+	{Key: "mac", Label: "MAC", Text: func(s models.SuspiciousTransaction) string { return s.MACAddress }},
+	{Key: "vehicle", Label: "Véhicule", Text: func(s models.SuspiciousTransaction) string { return s.Vehicle }},
+	{Key: "start", Label: "Début",
+		Text:  func(s models.SuspiciousTransaction) string { return formatDate(s.DatetimeStart) },
+		Value: func(s models.SuspiciousTransaction) any { return s.DatetimeStart }},
+	{Key: "energy", Label: "Énergie (kWh)", Class: "text-orange-700",
+		Text:  func(s models.SuspiciousTransaction) string { return fmt.Sprintf("%.2f", s.EnergyKwh) },
+		Value: func(s models.SuspiciousTransaction) any { return s.EnergyKwh }},
+}
+
+var attemptsColumns = []table.Column[models.MultiAttempt]{
+	{Key: "site", Label: "Site", Text: func(m models.MultiAttempt) string { return m.Site }},
+	{Key: "hour", Label: "Heure", Text: func(m models.MultiAttempt) string { return m.Heure }},
+	{Key: "mac", Label: "MAC", Text: func(m models.MultiAttempt) string { return m.MAC }},
+	{Key: "vehicle", Label: "Véhicule", Text: func(m models.MultiAttempt) string { return m.Vehicle }},
+	{Key: "attempts", Label: "Tentatives", Class: "font-semibold text-red-700",
+		Text:  func(m models.MultiAttempt) string { return strconv.Itoa(m.Tentatives) },
+		Value: func(m models.MultiAttempt) any { return m.Tentatives }},
+	{Key: "pdcs", Label: "PDC(s)", Text: func(m models.MultiAttempt) string { return m.PDCs }},
+}
+
+func sessionStart(s columnar.Row) any {
+	if t := s.DatetimeStart(); !t.IsZero() {
+		return t
+	}
+	return nil
+}
+
+func sessionStartText(s columnar.Row) string {
+	return formatDate(s.DatetimeStart())
+}
+
+// optionalText affiche un champ optionnel, "-" s'il est absent
+func optionalText(get func(columnar.Row) (int, bool)) func(columnar.Row) string {
+	return func(s columnar.Row) string {
+		if v, ok := get(s); ok {
+			return strconv.Itoa(v)
+		}
+		return "-"
+	}
+}
+
+func optionalValue(get func(columnar.Row) (int, bool)) func(columnar.Row) any {
+	return func(s columnar.Row) any {
+		if v, ok := get(s); ok {
+			return v
+		}
+		return nil
+	}
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("02/01/2006 15:04")
+}
+
+// withParam retourne l'URL de la requête avec un paramètre remplacé (retiré
+// si value est vide, pour qu'un champ HTMX le fournisse), pour recharger
+// l'onglet avec les mêmes filtres
+func withParam(r *http.Request, key, value string) string {
+	v := url.Values{}
+	for k, vals := range r.Form {
+		v[k] = vals
+	}
+	if value == "" {
+		v.Del(key)
+	} else {
+		v.Set(key, value)
+	}
+	return r.URL.Path + "?" + v.Encode()
+}
+
+// heatLevels est l'échelle de couleurs des cellules de pivot, par nombre
+// d'occurrences (même paliers que le dashboard Streamlit)
+var heatLevels = []struct {
+	max   int
+	style string
+}{
+	{2, "background-color: #E8F1FB"},
+	{6, "background-color: #CFE3F7"},
+	{15, "background-color: #A9CFF2"},
+	{25, "background-color: #7DB5EA"},
+	{50, "background-color: #4F97D9; color: white"},
+	{100, "background-color: #2F6FB7; color: white"},
+}
+
+// heatStyle colore une cellule de pivot selon son nombre d'occurrences
+func heatStyle(n int) template.CSS {
+	if n <= 0 {
+		return ""
+	}
+	for _, l := range heatLevels {
+		if n <= l.max {
+			return template.CSS(l.style)
+		}
+	}
+	return "background-color: #1F4F8F; color: white"
+}
+
+// heatScale colore une cellule de heatmap selon sa part du maximum, sur les
+// mêmes teintes que heatStyle
+func heatScale(n, max int) template.CSS {
+	if n <= 0 || max <= 0 {
+		return ""
+	}
+	level := (n*len(heatLevels) + max - 1) / max
+	if level > len(heatLevels) {
+		return "background-color: #1F4F8F; color: white"
+	}
+	return template.CSS(heatLevels[level-1].style)
+}
+
+// renderTable rend l'onglet complet, ou seulement son tableau quand HTMX le
+// recharge (pagination, tri, recherche)
+func (h *Handler) renderTable(w http.ResponseWriter, r *http.Request, name string, data interface{}, page table.Page) {
+	if r.Header.Get("HX-Target") == page.ID {
+		h.render(w, r, "table", page)
+		return
+	}
+	h.render(w, r, name, data)
+}
@@ -0,0 +1,161 @@
+// Package memo mémorise les résultats filtrés (sessions, cellules du cube,
+// KPIs) par jeu de filtres normalisé et version d'instantané, dans un LRU
+// borné partagé par toutes les requêtes.
+package memo
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// LRU est un cache borné en nombre d'entrées. Un LRU nil ou de capacité
+// nulle ne mémorise rien. Les valeurs sont partagées entre requêtes et ne
+// doivent pas être modifiées.
+type LRU struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+type entry struct {
+	key   string
+	value any
+}
+
+// Stats résume l'activité du LRU
+type Stats struct {
+	Entries int    `json:"entries"`
+	Max     int    `json:"max"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// New crée un LRU de max entrées
+func New(max int) *LRU {
+	return &LRU{
+		max:   max,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get retourne la valeur mémorisée pour key
+func (c *LRU) Get(key string) (any, bool) {
+	if c == nil || c.max <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.ll.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Add mémorise value pour key, en évinçant l'entrée la moins récemment
+// utilisée si le LRU est plein
+func (c *LRU) Add(key string, value any) {
+	if c == nil || c.max <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry).value = value
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: value})
+	for c.ll.Len() > c.max {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
+
+// Purge vide le LRU
+func (c *LRU) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Stats retourne l'activité du LRU
+func (c *LRU) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{Entries: c.ll.Len(), Max: c.max, Hits: c.hits, Misses: c.misses}
+}
+
+// Key encode de façon canonique un résultat kind calculé pour filters sur
+// l'instantané version. Seuls les critères effectifs comptent : la période
+// résolue (et non le mode de date qui l'a produite) et les listes triées et
+// dédoublonnées, pour que deux requêtes équivalentes partagent l'entrée.
+func Key(version uint64, kind string, filters models.Filters) string {
+	var b strings.Builder
+	b.WriteString(strconv.FormatUint(version, 10))
+	b.WriteByte('|')
+	b.WriteString(kind)
+	b.WriteByte('|')
+	b.WriteString(filters.DateStart.UTC().Format(time.RFC3339Nano))
+	b.WriteByte('|')
+	b.WriteString(filters.DateEnd.UTC().Format(time.RFC3339Nano))
+	for _, values := range [][]string{filters.Sites, filters.Sources, filters.TypesErreur, filters.Moments} {
+		values = canonical(values)
+		b.WriteByte('|')
+		b.WriteString(strconv.Itoa(len(values)))
+		for _, v := range values {
+			b.WriteByte(0)
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
+
+// canonical trie et dédoublonne une liste sans modifier l'originale
+func canonical(values []string) []string {
+	if len(values) < 2 {
+		return values
+	}
+
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+
+	out := sorted[:1]
+	for _, v := range sorted[1:] {
+		if v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
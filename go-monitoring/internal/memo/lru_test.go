@@ -0,0 +1,112 @@
+package memo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+func TestKey(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)
+	base := models.Filters{
+		DateStart:   start,
+		DateEnd:     end,
+		Sites:       []string{"A", "B"},
+		Sources:     []string{"prod"},
+		TypesErreur: []string{"Erreur_EVI", "Erreur_DownStream"},
+		Moments:     []string{"Init"},
+	}
+	paris := time.FixedZone("CET", 3600)
+	key := Key(1, "cells", base)
+
+	same := []struct {
+		name   string
+		mutate func(*models.Filters)
+	}{
+		{"ordre des sites", func(f *models.Filters) { f.Sites = []string{"B", "A"} }},
+		{"ordre des types", func(f *models.Filters) { f.TypesErreur = []string{"Erreur_DownStream", "Erreur_EVI"} }},
+		{"doublons", func(f *models.Filters) { f.Sites = []string{"B", "A", "B", "A"} }},
+		{"mode de date", func(f *models.Filters) { f.DateMode = "focus_month"; f.FocusYear = 2024; f.FocusMonth = 3 }},
+		{"jour ciblé", func(f *models.Filters) { f.FocusDay = start }},
+		{"fuseau", func(f *models.Filters) { f.DateStart = start.In(paris); f.DateEnd = end.In(paris) }},
+	}
+	for _, tt := range same {
+		f := base
+		tt.mutate(&f)
+		if got := Key(1, "cells", f); got != key {
+			t.Errorf("%s: Key = %q, want %q", tt.name, got, key)
+		}
+	}
+
+	different := []struct {
+		name    string
+		version uint64
+		kind    string
+		mutate  func(*models.Filters)
+	}{
+		{"version", 2, "cells", func(*models.Filters) {}},
+		{"nature", 1, "sessions", func(*models.Filters) {}},
+		{"début", 1, "cells", func(f *models.Filters) { f.DateStart = start.Add(time.Hour) }},
+		{"fin", 1, "cells", func(f *models.Filters) { f.DateEnd = end.AddDate(0, 0, 1) }},
+		{"site en moins", 1, "cells", func(f *models.Filters) { f.Sites = []string{"A"} }},
+		{"aucun site", 1, "cells", func(f *models.Filters) { f.Sites = nil }},
+		// Les valeurs ne se confondent pas d'une liste à l'autre
+		{"liste décalée", 1, "cells", func(f *models.Filters) { f.Sites = []string{"A", "B", "prod"}; f.Sources = nil }},
+		{"valeurs concaténées", 1, "cells", func(f *models.Filters) { f.Sites = []string{"AB"} }},
+	}
+	for _, tt := range different {
+		f := base
+		tt.mutate(&f)
+		if got := Key(tt.version, tt.kind, f); got == key {
+			t.Errorf("%s: Key = %q, want a different key", tt.name, got)
+		}
+	}
+
+	// La liste de l'appelant n'est pas réordonnée
+	sites := []string{"B", "A", "B"}
+	f := base
+	f.Sites = sites
+	Key(1, "cells", f)
+	if want := []string{"B", "A", "B"}; !reflect.DeepEqual(sites, want) {
+		t.Errorf("Key modified filters.Sites: %v, want %v", sites, want)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	c := New(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a evicted too early")
+	}
+	// b est maintenant la moins récemment utilisée
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b still cached, want it evicted")
+	}
+	c.Add("a", 10)
+	if v, ok := c.Get("a"); !ok || v != 10 {
+		t.Errorf("Get(a) = %v, %v, want 10, true", v, ok)
+	}
+
+	want := Stats{Entries: 2, Max: 2, Hits: 2, Misses: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+
+	c.Purge()
+	if _, ok := c.Get("a"); ok {
+		t.Error("a still cached after Purge")
+	}
+
+	// Un LRU nil ou de capacité nulle ne mémorise rien
+	for _, c := range []*LRU{nil, New(0)} {
+		c.Add("a", 1)
+		if _, ok := c.Get("a"); ok {
+			t.Errorf("LRU %v cached a value", c)
+		}
+	}
+}
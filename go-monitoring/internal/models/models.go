@@ -23,6 +23,8 @@ type Session struct {
 	SOCEnd              *float64  `db:"SOC End"`
 	MACAddress          string    `db:"MAC Address"`
 	Charge900V          int       `db:"charge_900V"`
+	// Source est le nom de la base d'origine (vide sans fédération)
+	Source              string
 }
 
 // Alerte représente une alerte de défaut récurrent
@@ -35,6 +37,7 @@ type Alerte struct {
 	Moment            string    `db:"moment"`
 	EVICode           *int      `db:"evi_code"`
 	DownstreamCodePC  *int      `db:"downstream_code_pc"`
+	Source            string
 }
 
 // Defaut représente un défaut actif ou historique
@@ -44,6 +47,7 @@ type Defaut struct {
 	DateFin    *time.Time `db:"date_fin"`
 	Defaut     string     `db:"defaut"`
 	Equipement string     `db:"eqp"`
+	Source     string
 }
 
 // SuspiciousTransaction représente une transaction suspecte (<1 kWh)
@@ -58,6 +62,7 @@ type SuspiciousTransaction struct {
 	EnergyKwh     float64   `db:"Energy (Kwh)"`
 	SOCStart      *float64  `db:"SOC Start"`
 	SOCEnd        *float64  `db:"SOC End"`
+	Source        string
 }
 
 // MultiAttempt représente un utilisateur avec multiples tentatives
@@ -75,6 +80,7 @@ type MultiAttempt struct {
 	SOCStartMax       *float64  `db:"SOC start max"`
 	SOCEndMin         *float64  `db:"SOC end min"`
 	SOCEndMax         *float64  `db:"SOC end max"`
+	Source            string
 }
 
 // ChargeMAC représente une charge avec informations MAC/véhicule
@@ -88,12 +94,14 @@ type ChargeMAC struct {
 	SOCStart      *float64  `db:"SOC Start"`
 	SOCEnd        *float64  `db:"SOC End"`
 	IsOK          bool      `db:"is_ok"`
+	Source        string
 }
 
 // StatsGlobal représente des statistiques globales
 type StatsGlobal struct {
 	Mois         string  `db:"mois"`
 	TauxReussite float64 `db:"tr"`
+	Source       string
 }
 
 // ChargesDaily représente le nombre de charges par jour
@@ -102,6 +110,7 @@ type ChargesDaily struct {
 	Day    time.Time `db:"day"`
 	Status string    `db:"Status"`
 	Nb     int       `db:"Nb"`
+	Source string
 }
 
 // DurationsSiteDaily représente les durées par site et jour
@@ -109,6 +118,7 @@ type DurationsSiteDaily struct {
 	Site   string    `db:"Site"`
 	Day    time.Time `db:"day"`
 	DurMin float64   `db:"dur_min"`
+	Source string
 }
 
 // DurationsPDCDaily représente les durées par PDC et jour
@@ -117,11 +127,13 @@ type DurationsPDCDaily struct {
 	PDC    string    `db:"PDC"`
 	Day    time.Time `db:"day"`
 	DurMin float64   `db:"dur_min"`
+	Source string
 }
 
 // Filters représente les filtres utilisateur
 type Filters struct {
 	Sites        []string  `json:"sites"`
+	Sources      []string  `json:"sources"`
 	DateMode     string    `json:"date_mode"`
 	DateStart    time.Time `json:"date_start"`
 	DateEnd      time.Time `json:"date_end"`
@@ -146,6 +158,7 @@ type KPISummary struct {
 // SiteStats représente les stats par site
 type SiteStats struct {
 	Site         string  `json:"site"`
+	Source       string  `json:"source,omitempty"`
 	Total        int     `json:"total"`
 	OK           int     `json:"ok"`
 	NOK          int     `json:"nok"`
@@ -175,3 +188,157 @@ type CodeOccurrence struct {
 	Percentage  float64           `json:"percentage"`
 	ByMoment    map[string]int    `json:"by_moment"`
 }
+
+// CodePivot croise des lignes (site ou site × PDC) avec les codes d'erreur,
+// regroupés par moment : Moments donne le premier niveau d'en-tête, Columns
+// le second, dans le même ordre que les comptes des lignes
+type CodePivot struct {
+	Moments []PivotMoment `json:"moments"`
+	Columns []PivotColumn `json:"columns"`
+	Rows    []PivotRow    `json:"rows"`
+	// Totals est le total de chaque colonne, Total le total général
+	Totals []int `json:"totals"`
+	Total  int   `json:"total"`
+}
+
+// PivotMoment est un groupe de colonnes du pivot
+type PivotMoment struct {
+	Moment string `json:"moment"`
+	Span   int    `json:"span"`
+}
+
+// PivotColumn est une colonne moment × code du pivot
+type PivotColumn struct {
+	Moment string `json:"moment"`
+	Code   int    `json:"code"`
+}
+
+// PivotRow est une ligne du pivot
+type PivotRow struct {
+	Site   string `json:"site"`
+	PDC    string `json:"pdc,omitempty"`
+	Counts []int  `json:"counts"`
+	Total  int    `json:"total"`
+	// Percentage est la part de la ligne dans le total général
+	Percentage float64 `json:"percentage"`
+}
+
+// SessionStats regroupe les statistiques d'énergie, de puissance, de SOC et
+// de durée des sessions filtrées (onglet statistiques)
+type SessionStats struct {
+	// EnergyTotal couvre tous les statuts, Energy les sessions OK et les
+	// NOK en fin de charge
+	EnergyTotal float64    `json:"energy_total"`
+	Energy      ValueStats `json:"energy"`
+	// Puissances, SOC et durées portent sur les sessions OK en fin de charge
+	MeanPower    ValueStats `json:"mean_power"`
+	MaxPower     ValueStats `json:"max_power"`
+	SOCStartMean float64    `json:"soc_start_mean"`
+	SOCEndMean   float64    `json:"soc_end_mean"`
+	// SOCGainMean est la moyenne de SOC fin - SOC début, sur les sessions
+	// qui ont les deux
+	SOCGainMean  float64 `json:"soc_gain_mean"`
+	DurationMean float64 `json:"duration_mean"`
+	Total        int     `json:"total"`
+	Total900V    int     `json:"total_900v"`
+	Pct900V      float64 `json:"pct_900v"`
+
+	EnergyHistogram    []HistogramBin `json:"energy_histogram"`
+	MeanPowerHistogram []HistogramBin `json:"mean_power_histogram"`
+	SOCStartHistogram  []HistogramBin `json:"soc_start_histogram"`
+	SOCEndHistogram    []HistogramBin `json:"soc_end_histogram"`
+	// DailyMaxEnergy est la charge la plus énergétique de chaque jour
+	DailyMaxEnergy []DailyMax `json:"daily_max_energy"`
+}
+
+// ValueStats résume une mesure : moyenne, maximum et session du maximum
+type ValueStats struct {
+	Count   int       `json:"count"`
+	Mean    float64   `json:"mean"`
+	Max     float64   `json:"max"`
+	MaxAt   time.Time `json:"max_at"`
+	MaxSite string    `json:"max_site"`
+	MaxPDC  string    `json:"max_pdc"`
+}
+
+// HistogramBin est une classe [From, To) d'histogramme
+type HistogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// DailyMax est le maximum d'une mesure sur un jour, et son site
+type DailyMax struct {
+	Day   time.Time `json:"day"`
+	Value float64   `json:"value"`
+	Site  string    `json:"site"`
+	PDC   string    `json:"pdc"`
+}
+
+// VehicleStats représente le taux de réussite d'un type de véhicule
+type VehicleStats struct {
+	Vehicle      string  `json:"vehicle"`
+	Total        int     `json:"total"`
+	OK           int     `json:"ok"`
+	NOK          int     `json:"nok"`
+	TauxReussite float64 `json:"taux_reussite"`
+	TauxEchec    float64 `json:"taux_echec"`
+}
+
+// OperatingTime regroupe les durées de fonctionnement (minutes de charge des
+// tables de durées) sur la période filtrée
+type OperatingTime struct {
+	// Start et End bornent la période filtrée, réduite aux jours couverts
+	// par les tables ; PeriodHours est sa durée, base des taux d'utilisation
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	PeriodHours float64   `json:"period_hours"`
+	TotalHours  float64   `json:"total_hours"`
+	// Sites est trié par heures décroissantes, PDCs par site puis heures
+	// décroissantes
+	Sites []OperatingHours `json:"sites"`
+	PDCs  []OperatingHours `json:"pdcs"`
+	// Trends donne les heures de chaque site par jour, alignées sur Days
+	Days   []time.Time   `json:"days"`
+	Trends []HoursSeries `json:"trends"`
+}
+
+// OperatingHours est le temps de fonctionnement d'un site ou d'un PDC ;
+// Utilization rapporte les heures à PDCCount × la durée de la période (%)
+type OperatingHours struct {
+	Site        string  `json:"site"`
+	PDC         string  `json:"pdc,omitempty"`
+	PDCCount    int     `json:"pdc_count"`
+	Hours       float64 `json:"hours"`
+	Utilization float64 `json:"utilization"`
+}
+
+// HoursSeries est une série d'heures par jour
+type HoursSeries struct {
+	Name  string    `json:"name"`
+	Hours []float64 `json:"hours"`
+}
+
+// HourlyAnalysis répartit les charges par heure de début, par site
+type HourlyAnalysis struct {
+	// Sites est trié par nom ; Max est le plus grand nombre de charges d'un
+	// site sur une heure, borne de l'échelle de la heatmap
+	Sites []SiteHourly `json:"sites"`
+	Max   int          `json:"max"`
+}
+
+// SiteHourly est la répartition horaire des charges d'un site
+type SiteHourly struct {
+	Site  string  `json:"site"`
+	Total int     `json:"total"`
+	OK    [24]int `json:"ok"`
+	NOK   [24]int `json:"nok"`
+	// Counts est OK + NOK pour chaque heure
+	Counts    [24]int `json:"counts"`
+	PeakHour  int     `json:"peak_hour"`
+	PeakCount int     `json:"peak_count"`
+	// MedianHour est la première heure où le cumul atteint la moitié des
+	// charges
+	MedianHour int `json:"median_hour"`
+}
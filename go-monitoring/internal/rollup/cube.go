@@ -0,0 +1,216 @@
+// Package rollup pré-agrège les sessions par site × PDC × jour × moment ×
+// type d'erreur × code. Le cube est construit à chaque refresh ; les KPI,
+// stats par site/PDC, comptages par moment et occurrences de codes s'en
+// servent au lieu de recompter les sessions brutes.
+package rollup
+
+import (
+	"sort"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+const day = 24 * time.Hour
+
+// Key identifie une cellule du cube
+type Key struct {
+	Source string
+	Site   string
+	PDC    string
+	// Day est le jour (UTC) de début des sessions ; zéro pour les sessions
+	// sans date de début
+	Day time.Time
+	// Midnight distingue les sessions démarrées exactement à 00:00:00 : le
+	// filtre de dates inclut sa borne de fin, donc ces sessions du jour de fin
+	Midnight   bool
+	Moment     string
+	TypeErreur string
+	// EVICode, DSCode et EVIStep valent 0 en l'absence de valeur ; EVIStep
+	// donne le moment du pivot de projection (utils.MapMoment)
+	EVICode int
+	DSCode  int
+	EVIStep int
+}
+
+// Cell agrège les sessions d'une même clé
+type Cell struct {
+	Key
+	OK        int
+	NOK       int
+	EnergyOK  float64
+	EnergyNOK float64
+}
+
+// Total retourne le nombre de sessions de la cellule
+func (c Cell) Total() int {
+	return c.OK + c.NOK
+}
+
+// Cube est l'ensemble immuable des cellules, triées par jour
+type Cube struct {
+	cells []Cell
+	// undated est le nombre de cellules sans jour, en tête de cells
+	undated int
+	// sessions est le nombre de sessions agrégées
+	sessions int
+}
+
+// Build agrège les sessions dans un nouveau cube
+func Build(sessions columnar.View) *Cube {
+	cells := Aggregate(sessions)
+	sort.Slice(cells, func(i, j int) bool {
+		return cells[i].Day.Before(cells[j].Day)
+	})
+
+	c := &Cube{cells: cells, sessions: sessions.Len()}
+	c.undated = sort.Search(len(cells), func(i int) bool {
+		return !cells[i].Day.IsZero()
+	})
+	return c
+}
+
+// Aggregate regroupe des sessions en cellules, sans ordre particulier
+func Aggregate(sessions columnar.View) []Cell {
+	index := make(map[Key]int)
+	var cells []Cell
+
+	for it := sessions.Iter(); it.Next(); {
+		s := it.Row()
+		k := keyOf(s)
+		i, ok := index[k]
+		if !ok {
+			i = len(cells)
+			index[k] = i
+			cells = append(cells, Cell{Key: k})
+		}
+
+		c := &cells[i]
+		energy, _ := s.EnergyKwh()
+		if s.OK() {
+			c.OK++
+			c.EnergyOK += energy
+		} else {
+			c.NOK++
+			c.EnergyNOK += energy
+		}
+	}
+
+	return cells
+}
+
+func keyOf(s columnar.Row) Key {
+	k := Key{
+		Source:     s.Source(),
+		Site:       s.Site(),
+		PDC:        s.PDC(),
+		Moment:     s.Moment(),
+		TypeErreur: s.TypeErreur(),
+	}
+	if start := s.DatetimeStart(); !start.IsZero() {
+		t := start.UTC()
+		k.Day = t.Truncate(day)
+		k.Midnight = t.Equal(k.Day)
+	}
+	k.EVICode, _ = s.EVIErrorCode()
+	k.DSCode, _ = s.DownstreamCodePC()
+	k.EVIStep, _ = s.EVIMomentStep()
+	return k
+}
+
+// Len retourne le nombre de cellules du cube
+func (c *Cube) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.cells)
+}
+
+// Sessions retourne le nombre de sessions agrégées dans le cube
+func (c *Cube) Sessions() int {
+	if c == nil {
+		return 0
+	}
+	return c.sessions
+}
+
+// Answerable indique si le cube peut répondre exactement aux filtres : la
+// période doit commencer et finir sur des jours entiers (UTC)
+func Answerable(filters models.Filters) bool {
+	return dayAligned(filters.DateStart) && dayAligned(filters.DateEnd)
+}
+
+func dayAligned(t time.Time) bool {
+	return !t.IsZero() && t.Equal(t.UTC().Truncate(day))
+}
+
+// Select retourne les cellules qui passent les filtres, avec la même
+// sémantique que utils.MatchSession : les sessions OK sont toujours
+// retenues, les NOK seulement si leur type d'erreur et leur moment sont
+// sélectionnés. ok vaut false si le cube ne peut pas répondre (voir
+// Answerable).
+func (c *Cube) Select(filters models.Filters) (cells []Cell, ok bool) {
+	if !Answerable(filters) {
+		return nil, false
+	}
+	if c == nil {
+		return nil, true
+	}
+
+	start := filters.DateStart.UTC()
+	end := filters.DateEnd.UTC()
+	lo := sort.Search(len(c.cells), func(i int) bool {
+		return !c.cells[i].Day.Before(start)
+	})
+	lo = max(lo, c.undated)
+	hi := sort.Search(len(c.cells), func(i int) bool {
+		return c.cells[i].Day.After(end)
+	})
+
+	sites := set(filters.Sites)
+	sources := set(filters.Sources)
+	types := set(filters.TypesErreur)
+	moments := set(filters.Moments)
+
+	keep := func(cell Cell) {
+		if sites != nil && !sites[cell.Site] {
+			return
+		}
+		if sources != nil && !sources[cell.Source] {
+			return
+		}
+		// Les NOK ne sont retenus que pour les types et moments choisis
+		if (types != nil && !types[cell.TypeErreur]) || (moments != nil && !moments[cell.Moment]) {
+			cell.NOK = 0
+			cell.EnergyNOK = 0
+		}
+		if cell.Total() > 0 {
+			cells = append(cells, cell)
+		}
+	}
+
+	for _, cell := range c.cells[:c.undated] {
+		keep(cell)
+	}
+	for _, cell := range c.cells[lo:max(lo, hi)] {
+		// Le jour de fin n'est inclus que pour les sessions de 00:00:00
+		if cell.Day.Equal(end) && !cell.Midnight {
+			continue
+		}
+		keep(cell)
+	}
+
+	return cells, true
+}
+
+func set(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[v] = true
+	}
+	return m
+}
@@ -0,0 +1,190 @@
+package rollup_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/rollup"
+	"github.com/monitoring/charging-stations/internal/utils"
+)
+
+func intp(v int) *int { return &v }
+
+func floatp(v float64) *float64 { return &v }
+
+func at(day, hour, min int) time.Time {
+	return time.Date(2024, time.March, day, hour, min, 0, 0, time.UTC)
+}
+
+// fixture couvre les cas délicats du cube : sessions de 00:00:00 le jour
+// de fin, sessions plus tard ce même jour, veille de la période, sessions
+// sans date, NOK de types et moments non sélectionnés, code DownStream
+// 8192 et codes absents
+func fixture() *columnar.Sessions {
+	return columnar.FromRows([]models.Session{
+		{ID: "1", DatetimeStart: at(1, 0, 0), Site: "A", PDC: "A1", Source: "s1", EnergyKwh: floatp(10)},
+		{ID: "2", DatetimeStart: at(1, 8, 30), Site: "A", PDC: "A1", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Init", EVIErrorCode: intp(12), EnergyKwh: floatp(0.5)},
+		{ID: "3", DatetimeStart: at(1, 9, 0), Site: "A", PDC: "A2", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_DownStream", Moment: "Charge", DownstreamCodePC: intp(8192)},
+		{ID: "4", DatetimeStart: at(1, 9, 15), Site: "A", PDC: "A2", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_DownStream", Moment: "Charge", DownstreamCodePC: intp(4)},
+		{ID: "5", DatetimeStart: at(2, 12, 0), Site: "B", PDC: "B1", Source: "s2", EnergyKwh: floatp(20.25)},
+		{ID: "6", DatetimeStart: at(2, 12, 0), Site: "B", PDC: "B1", Source: "s2", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Fin de charge", EVIErrorCode: intp(7)},
+		{ID: "7", DatetimeStart: at(2, 23, 59), Site: "B", PDC: "B2", Source: "s2", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Init", EVIErrorCode: intp(12)},
+		{ID: "8", DatetimeStart: at(3, 0, 0), Site: "A", PDC: "A1", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Init", EVIErrorCode: intp(7)},
+		{ID: "9", DatetimeStart: at(3, 0, 0), Site: "B", PDC: "B1", Source: "s2"},
+		{ID: "10", DatetimeStart: at(3, 10, 0), Site: "A", PDC: "A1", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Init", EVIErrorCode: intp(12)},
+		{ID: "11", DatetimeStart: at(3, 10, 0), Site: "B", PDC: "B2", Source: "s2"},
+		{ID: "12", DatetimeStart: at(4, 0, 0), Site: "A", PDC: "A1", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Init", EVIErrorCode: intp(3)},
+		{ID: "13", Site: "C", PDC: "C1", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_DownStream", Moment: "Init", DownstreamCodePC: intp(9)},
+		{ID: "14", Site: "C", PDC: "C1", Source: "s1"},
+		{ID: "15", DatetimeStart: at(1, 10, 0), Site: "C", PDC: "C1", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_EVI"},
+	})
+}
+
+// cells reproduit Cache.Cells : le cube quand il peut répondre, sinon les
+// sessions filtrées agrégées
+func cells(cube *rollup.Cube, sessions columnar.View, filters models.Filters) ([]rollup.Cell, bool) {
+	if cells, ok := cube.Select(filters); ok {
+		return cells, true
+	}
+	return rollup.Aggregate(utils.FilterSessions(sessions, filters)), false
+}
+
+func TestSelectMatchesRawSessions(t *testing.T) {
+	sessions := fixture().All()
+	cube := rollup.Build(sessions)
+
+	tests := []struct {
+		name     string
+		filters  models.Filters
+		fromCube bool
+	}{
+		{
+			name:     "période complète",
+			filters:  models.Filters{DateStart: at(1, 0, 0), DateEnd: at(3, 0, 0)},
+			fromCube: true,
+		},
+		{
+			name:     "un seul jour",
+			filters:  models.Filters{DateStart: at(2, 0, 0), DateEnd: at(2, 0, 0)},
+			fromCube: true,
+		},
+		{
+			name:     "période sans session",
+			filters:  models.Filters{DateStart: at(20, 0, 0), DateEnd: at(25, 0, 0)},
+			fromCube: true,
+		},
+		{
+			name:     "types d'erreur",
+			filters:  models.Filters{DateStart: at(1, 0, 0), DateEnd: at(4, 0, 0), TypesErreur: []string{"Erreur_EVI"}},
+			fromCube: true,
+		},
+		{
+			name:     "moments",
+			filters:  models.Filters{DateStart: at(1, 0, 0), DateEnd: at(4, 0, 0), Moments: []string{"Init"}},
+			fromCube: true,
+		},
+		{
+			name:     "types et moments",
+			filters:  models.Filters{DateStart: at(1, 0, 0), DateEnd: at(4, 0, 0), TypesErreur: []string{"Erreur_DownStream"}, Moments: []string{"Charge", "Init"}},
+			fromCube: true,
+		},
+		{
+			name:     "sites et bases",
+			filters:  models.Filters{DateStart: at(1, 0, 0), DateEnd: at(4, 0, 0), Sites: []string{"A", "C"}, Sources: []string{"s1"}},
+			fromCube: true,
+		},
+		{
+			name:     "période non alignée",
+			filters:  models.Filters{DateStart: at(1, 8, 45), DateEnd: at(3, 9, 0)},
+			fromCube: false,
+		},
+		{
+			name:     "période non alignée avec types",
+			filters:  models.Filters{DateStart: at(1, 8, 45), DateEnd: at(3, 9, 0), TypesErreur: []string{"Erreur_EVI"}, Moments: []string{"Init"}},
+			fromCube: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fromCube := cells(cube, sessions, tt.filters)
+			if fromCube != tt.fromCube {
+				t.Fatalf("answered from cube = %v, want %v", fromCube, tt.fromCube)
+			}
+			raw := utils.FilterSessions(sessions, tt.filters)
+			want := rollup.Aggregate(raw)
+
+			checkRawCounts(t, got, raw)
+
+			if g, w := utils.KPIsFromCells(got), utils.KPIsFromCells(want); g != w {
+				t.Errorf("KPIs = %+v, want %+v", g, w)
+			}
+			if g, w := sortedSiteStats(utils.StatsBySiteFromCells(got)), sortedSiteStats(utils.StatsBySiteFromCells(want)); !reflect.DeepEqual(g, w) {
+				t.Errorf("StatsBySite = %+v, want %+v", g, w)
+			}
+			for _, site := range []string{"A", "B", "C"} {
+				if g, w := sortedPDCStats(utils.StatsByPDCFromCells(got, site)), sortedPDCStats(utils.StatsByPDCFromCells(want, site)); !reflect.DeepEqual(g, w) {
+					t.Errorf("StatsByPDC(%s) = %+v, want %+v", site, g, w)
+				}
+			}
+			if g, w := utils.MomentCountsFromCells(got), utils.MomentCountsFromCells(want); !reflect.DeepEqual(g, w) {
+				t.Errorf("MomentCounts = %+v, want %+v", g, w)
+			}
+			for _, isEVI := range []bool{true, false} {
+				if g, w := utils.CodeOccurrencesFromCells(got, isEVI), utils.CodeOccurrencesFromCells(want, isEVI); !reflect.DeepEqual(g, w) {
+					t.Errorf("CodeOccurrences(evi=%v) = %+v, want %+v", isEVI, g, w)
+				}
+				for _, byPDC := range []bool{true, false} {
+					if g, w := utils.CodePivotFromCells(got, isEVI, byPDC), utils.CodePivotFromCells(want, isEVI, byPDC); !reflect.DeepEqual(g, w) {
+						t.Errorf("CodePivot(evi=%v, byPDC=%v) = %+v, want %+v", isEVI, byPDC, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+// checkRawCounts compare les totaux des cellules à un comptage direct des
+// sessions filtrées, sans passer par Aggregate
+func checkRawCounts(t *testing.T, cells []rollup.Cell, raw columnar.View) {
+	t.Helper()
+
+	var ok, nok int
+	var energy float64
+	for it := raw.Iter(); it.Next(); {
+		s := it.Row()
+		if s.OK() {
+			ok++
+		} else {
+			nok++
+		}
+		e, _ := s.EnergyKwh()
+		energy += e
+	}
+
+	var cellOK, cellNOK int
+	var cellEnergy float64
+	for _, c := range cells {
+		cellOK += c.OK
+		cellNOK += c.NOK
+		cellEnergy += c.EnergyOK + c.EnergyNOK
+	}
+
+	if cellOK != ok || cellNOK != nok || cellEnergy != energy {
+		t.Errorf("cells OK/NOK/energy = %d/%d/%v, raw sessions = %d/%d/%v", cellOK, cellNOK, cellEnergy, ok, nok, energy)
+	}
+}
+
+func sortedSiteStats(stats []models.SiteStats) []models.SiteStats {
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Site < stats[j].Site })
+	return stats
+}
+
+func sortedPDCStats(stats []models.PDCStats) []models.PDCStats {
+	sort.Slice(stats, func(i, j int) bool { return stats[i].PDC < stats[j].PDC })
+	return stats
+}
@@ -0,0 +1,255 @@
+// Package store indexe les sessions du cache pour les filtres du tableau de
+// bord : tri par date de début pour les recherches par plage, et index par
+// site et par PDC pour éviter de parcourir toutes les sessions.
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/utils"
+)
+
+// ctxCheckEvery fixe la fréquence de vérification d'annulation dans les boucles
+const ctxCheckEvery = 4096
+
+// Sessions est un index immuable des sessions, construit à chaque refresh.
+// Les positions des index sont croissantes, donc elles aussi triées par date.
+type Sessions struct {
+	// cols est trié par date de début ; les sessions sans date de début
+	// (toujours retenues par le filtre de dates) sont en tête
+	cols    *columnar.Sessions
+	undated int
+
+	bySite map[string][]int32
+	byPDC  map[string]map[string][]int32
+
+	sites       []string
+	siteSources map[string]string
+}
+
+// New construit l'index sur les sessions de une ou plusieurs bases. Avec
+// une seule base, ses colonnes (déjà triées) sont partagées ; sinon elles
+// sont fusionnées dans de nouvelles colonnes.
+func New(parts ...*columnar.Sessions) *Sessions {
+	var cols *columnar.Sessions
+	switch len(parts) {
+	case 0:
+		cols = columnar.FromRows(nil)
+	case 1:
+		cols = parts[0]
+	default:
+		n := 0
+		for _, p := range parts {
+			n += p.Len()
+		}
+		b := columnar.NewBuilder(n)
+		for _, p := range parts {
+			b.AppendView(p.All())
+		}
+		cols = b.Build()
+	}
+
+	s := &Sessions{
+		cols:   cols,
+		bySite: make(map[string][]int32),
+		byPDC:  make(map[string]map[string][]int32),
+	}
+	s.undated = sort.Search(cols.Len(), func(i int) bool {
+		return !cols.Row(i).DatetimeStart().IsZero()
+	})
+
+	for it := cols.All().Iter(); it.Next(); {
+		row := it.Row()
+		site, pdc, i := row.Site(), row.PDC(), int32(row.Index())
+		s.bySite[site] = append(s.bySite[site], i)
+
+		pdcs := s.byPDC[site]
+		if pdcs == nil {
+			pdcs = make(map[string][]int32)
+			s.byPDC[site] = pdcs
+		}
+		pdcs[pdc] = append(pdcs[pdc], i)
+	}
+
+	for site := range s.bySite {
+		if site != "" {
+			s.sites = append(s.sites, site)
+		}
+	}
+	sort.Strings(s.sites)
+	s.siteSources = utils.GetSiteSources(cols.All())
+
+	return s
+}
+
+// Columns retourne les sessions en colonnes, triées par date de début
+func (s *Sessions) Columns() *columnar.Sessions {
+	if s == nil {
+		return nil
+	}
+	return s.cols
+}
+
+// All retourne toutes les sessions, triées par date de début
+func (s *Sessions) All() columnar.View {
+	if s == nil {
+		return columnar.View{}
+	}
+	return s.cols.All()
+}
+
+// Len retourne le nombre de sessions indexées
+func (s *Sessions) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.cols.Len()
+}
+
+// Sites retourne la liste triée des sites
+func (s *Sessions) Sites() []string {
+	if s == nil {
+		return nil
+	}
+	return s.sites
+}
+
+// SiteSources associe chaque site à sa ou ses bases d'origine
+func (s *Sessions) SiteSources() map[string]string {
+	if s == nil {
+		return nil
+	}
+	return s.siteSources
+}
+
+// PDCs retourne la liste triée des PDC d'un site
+func (s *Sessions) PDCs(site string) []string {
+	if s == nil {
+		return nil
+	}
+
+	var pdcs []string
+	for pdc := range s.byPDC[site] {
+		if pdc != "" {
+			pdcs = append(pdcs, pdc)
+		}
+	}
+	sort.Strings(pdcs)
+	return pdcs
+}
+
+// Range retourne les sessions démarrées entre start et end inclus, plus
+// les sessions sans date, sans liste d'indices quand il n'y en a pas
+func (s *Sessions) Range(start, end time.Time) columnar.View {
+	if s == nil {
+		return columnar.View{}
+	}
+
+	lo, hi := s.bounds(start, end)
+	if s.undated == 0 {
+		return s.cols.Slice(lo, hi)
+	}
+
+	idx := make([]int32, 0, s.undated+hi-lo)
+	for i := 0; i < s.undated; i++ {
+		idx = append(idx, int32(i))
+	}
+	for i := lo; i < hi; i++ {
+		idx = append(idx, int32(i))
+	}
+	return s.cols.Select(idx)
+}
+
+// PDC retourne les sessions d'un PDC démarrées entre start et end inclus
+func (s *Sessions) PDC(site, pdc string, start, end time.Time) columnar.View {
+	if s == nil {
+		return columnar.View{}
+	}
+	return s.cols.Select(s.positions(s.byPDC[site][pdc], start, end))
+}
+
+// Filter applique les filtres du tableau de bord. La plage de dates est
+// résolue par recherche dichotomique, les sites par leur index ; les autres
+// critères sont vérifiés ligne à ligne avec utils.MatchSession. Le résultat
+// est trié par date de début, comme les sessions du cache.
+func (s *Sessions) Filter(ctx context.Context, filters models.Filters) (columnar.View, error) {
+	if s == nil {
+		return columnar.View{}, ctx.Err()
+	}
+
+	if len(filters.Sites) == 0 {
+		return utils.FilterSessionsContext(ctx, s.Range(filters.DateStart, filters.DateEnd), filters)
+	}
+
+	var idx []int32
+	for _, site := range dedupe(filters.Sites) {
+		idx = append(idx, s.positions(s.bySite[site], filters.DateStart, filters.DateEnd)...)
+	}
+	if len(filters.Sites) > 1 {
+		sort.Slice(idx, func(i, j int) bool { return idx[i] < idx[j] })
+	}
+
+	var filtered []int32
+	for n, i := range idx {
+		if n%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return columnar.View{}, err
+			}
+		}
+		if utils.MatchSession(s.cols.Row(int(i)), filters) {
+			filtered = append(filtered, i)
+		}
+	}
+
+	return s.cols.Select(filtered), nil
+}
+
+// bounds retourne les bornes [lo, hi) de la plage dans cols
+func (s *Sessions) bounds(start, end time.Time) (int, int) {
+	lo := sort.Search(s.cols.Len(), func(i int) bool {
+		return !s.cols.Row(i).DatetimeStart().Before(start)
+	})
+	lo = max(lo, s.undated)
+	hi := sort.Search(s.cols.Len(), func(i int) bool {
+		return s.cols.Row(i).DatetimeStart().After(end)
+	})
+	return lo, max(lo, hi)
+}
+
+// positions restreint une liste de positions croissantes à la plage de
+// dates, en gardant les sessions sans date
+func (s *Sessions) positions(idx []int32, start, end time.Time) []int32 {
+	lo, hi := s.bounds(start, end)
+
+	undated := searchInt32(idx, s.undated)
+	from := searchInt32(idx, lo)
+	to := searchInt32(idx, hi)
+	if undated == 0 {
+		return idx[from:to]
+	}
+
+	out := make([]int32, 0, undated+to-from)
+	out = append(out, idx[:undated]...)
+	return append(out, idx[from:to]...)
+}
+
+// searchInt32 retourne la première position de idx (croissant) >= i
+func searchInt32(idx []int32, i int) int {
+	return sort.Search(len(idx), func(k int) bool { return int(idx[k]) >= i })
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := values[:0:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/utils"
+)
+
+func at(day, hour int) time.Time {
+	return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC)
+}
+
+func fixture() []*columnar.Sessions {
+	return []*columnar.Sessions{
+		columnar.FromRows([]models.Session{
+			{ID: "a1", DatetimeStart: at(1, 8), Site: "A", PDC: "A1", Source: "s1"},
+			{ID: "a2", DatetimeStart: at(2, 0), Site: "A", PDC: "A2", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Init"},
+			{ID: "a3", DatetimeStart: at(3, 0), Site: "A", PDC: "A1", Source: "s1", StateOfCharge: 1, TypeErreur: "Erreur_DownStream", Moment: "Charge"},
+			{ID: "a4", DatetimeStart: at(3, 10), Site: "A", PDC: "A1", Source: "s1"},
+			{ID: "a0", Site: "A", PDC: "A2", Source: "s1"},
+		}),
+		columnar.FromRows([]models.Session{
+			{ID: "b1", DatetimeStart: at(1, 23), Site: "B", PDC: "B1", Source: "s2", StateOfCharge: 1, TypeErreur: "Erreur_EVI", Moment: "Charge"},
+			{ID: "b2", DatetimeStart: at(2, 12), Site: "B", PDC: "B1", Source: "s2"},
+			{ID: "b3", DatetimeStart: at(4, 0), Site: "B", PDC: "B2", Source: "s2"},
+			{ID: "c1", DatetimeStart: at(2, 6), Site: "C", PDC: "C1", Source: "s2"},
+		}),
+	}
+}
+
+func ids(v columnar.View) []string {
+	var out []string
+	for it := v.Iter(); it.Next(); {
+		out = append(out, it.Row().ID())
+	}
+	return out
+}
+
+// Filter doit rendre exactement ce que rend un parcours complet avec
+// utils.FilterSessions, dans le même ordre
+func TestFilterMatchesScan(t *testing.T) {
+	s := New(fixture()...)
+
+	tests := []struct {
+		name    string
+		filters models.Filters
+	}{
+		{"jour de fin inclus à minuit", models.Filters{DateStart: at(2, 0), DateEnd: at(3, 0)}},
+		{"période vide", models.Filters{DateStart: at(10, 0), DateEnd: at(11, 0)}},
+		{"un site", models.Filters{DateStart: at(1, 0), DateEnd: at(3, 0), Sites: []string{"A"}}},
+		{"plusieurs sites en désordre", models.Filters{DateStart: at(1, 0), DateEnd: at(4, 0), Sites: []string{"C", "A", "B"}}},
+		{"site en double", models.Filters{DateStart: at(1, 0), DateEnd: at(4, 0), Sites: []string{"B", "B"}}},
+		{"site inconnu", models.Filters{DateStart: at(1, 0), DateEnd: at(4, 0), Sites: []string{"Z"}}},
+		{"sites, types et moments", models.Filters{DateStart: at(1, 0), DateEnd: at(4, 0), Sites: []string{"A", "B"}, TypesErreur: []string{"Erreur_EVI"}, Moments: []string{"Init"}}},
+		{"bases", models.Filters{DateStart: at(1, 0), DateEnd: at(4, 0), Sources: []string{"s2"}}},
+		{"borne non alignée", models.Filters{DateStart: at(1, 9), DateEnd: at(2, 6)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Filter(context.Background(), tt.filters)
+			if err != nil {
+				t.Fatalf("Filter: %v", err)
+			}
+			want := utils.FilterSessions(s.All(), tt.filters)
+			if g, w := ids(got), ids(want); !reflect.DeepEqual(g, w) {
+				t.Errorf("Filter = %v, want %v", g, w)
+			}
+		})
+	}
+}
+
+func TestRangeAndPDC(t *testing.T) {
+	s := New(fixture()...)
+
+	tests := []struct {
+		name string
+		got  columnar.View
+		want []string
+	}{
+		// Les sessions sans date sont toujours retenues, en tête
+		{"plage", s.Range(at(2, 0), at(3, 0)), []string{"a0", "a2", "c1", "b2", "a3"}},
+		{"PDC", s.PDC("A", "A1", at(1, 0), at(3, 0)), []string{"a1", "a3"}},
+		{"PDC avec session sans date", s.PDC("A", "A2", at(1, 0), at(4, 0)), []string{"a0", "a2"}},
+		{"PDC d'un autre site", s.PDC("B", "A1", at(1, 0), at(4, 0)), nil},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.got); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if got, want := s.Sites(), []string{"A", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sites = %v, want %v", got, want)
+	}
+	if got, want := s.PDCs("A"), []string{"A1", "A2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PDCs(A) = %v, want %v", got, want)
+	}
+}
+
+func TestFilterCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s := New(fixture()...)
+	if _, err := s.Filter(ctx, models.Filters{DateStart: at(1, 0), DateEnd: at(4, 0), Sites: []string{"A"}}); err == nil {
+		t.Error("Filter on a cancelled context returned no error")
+	}
+}
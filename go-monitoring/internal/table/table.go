@@ -0,0 +1,331 @@
+// Package table pagine, trie et filtre côté serveur les listes affichées
+// par les onglets. Un tableau est décrit par ses colonnes ; Build applique
+// les paramètres de la requête (page, taille, tri, recherche) et produit la
+// page que rend le gabarit "table".
+package table
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tailles de page proposées ; la première sert par défaut
+var PageSizes = []int{50, 25, 100, 200}
+
+// Paramètres de requête du composant
+const (
+	ParamPage  = "page"
+	ParamSize  = "size"
+	ParamSort  = "sort"
+	ParamDir   = "dir"
+	ParamQuery = "q"
+)
+
+// Column décrit une colonne d'un tableau de T
+type Column[T any] struct {
+	// Key identifie la colonne dans le paramètre de tri
+	Key   string
+	Label string
+	// Text est le texte affiché, sur lequel porte aussi la recherche
+	Text func(T) string
+	// Value est la valeur de tri (string, int, float64, time.Time ou nil
+	// pour une valeur absente) ; à défaut, le tri se fait sur Text
+	Value func(T) any
+	// Class est la classe CSS des cellules
+	Class string
+}
+
+// Params sont les paramètres de pagination, tri et recherche d'une requête
+type Params struct {
+	Page  int
+	Size  int
+	Sort  string
+	Desc  bool
+	Query string
+}
+
+// ParseParams lit les paramètres du composant (formulaire ou URL)
+func ParseParams(r *http.Request) Params {
+	p := Params{
+		Sort:  r.FormValue(ParamSort),
+		Desc:  r.FormValue(ParamDir) == "desc",
+		Query: strings.TrimSpace(r.FormValue(ParamQuery)),
+	}
+	p.Page, _ = strconv.Atoi(r.FormValue(ParamPage))
+	p.Size, _ = strconv.Atoi(r.FormValue(ParamSize))
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if !validSize(p.Size) {
+		p.Size = PageSizes[0]
+	}
+	return p
+}
+
+func validSize(size int) bool {
+	for _, s := range PageSizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Header est l'en-tête d'une colonne rendue
+type Header struct {
+	Key    string
+	Label  string
+	Sorted bool
+	Desc   bool
+}
+
+// Cell est une cellule rendue
+type Cell struct {
+	Text  string
+	Class string
+}
+
+// Page est une page de tableau prête à rendre
+type Page struct {
+	// ID est l'identifiant HTML du tableau, cible des requêtes HTMX
+	ID string
+	// Empty est le message affiché sans aucune ligne
+	Empty   string
+	Headers []Header
+	Rows    [][]Cell
+	Params
+
+	// Total est le nombre de lignes avant recherche, Matched après
+	Total   int
+	Matched int
+	Pages   int
+
+	// path et base (paramètres de la requête hors composant) construisent
+	// les liens de pagination et de tri
+	path string
+	base url.Values
+}
+
+// Build trie, filtre et pagine rows selon p. r fournit le chemin et les
+// autres paramètres (filtres globaux) à conserver dans les liens.
+func Build[T any](r *http.Request, id, empty string, rows []T, cols []Column[T], p Params) Page {
+	page := Page{
+		ID:     id,
+		Empty:  empty,
+		Params: p,
+		Total:  len(rows),
+		path:   r.URL.Path,
+		base:   baseValues(r),
+	}
+
+	sortCol := -1
+	for i, c := range cols {
+		h := Header{Key: c.Key, Label: c.Label}
+		if c.Key == p.Sort {
+			sortCol = i
+			h.Sorted, h.Desc = true, p.Desc
+		}
+		page.Headers = append(page.Headers, h)
+	}
+
+	// Recherche : une ligne est gardée si une colonne contient le texte
+	matched := rows
+	if p.Query != "" {
+		q := strings.ToLower(p.Query)
+		matched = nil
+		for _, row := range rows {
+			for _, c := range cols {
+				if strings.Contains(strings.ToLower(c.Text(row)), q) {
+					matched = append(matched, row)
+					break
+				}
+			}
+		}
+	}
+	page.Matched = len(matched)
+
+	// Tri stable sur une copie : rows peut appartenir au cache
+	if sortCol >= 0 {
+		c := cols[sortCol]
+		value := c.Value
+		if value == nil {
+			value = func(row T) any { return c.Text(row) }
+		}
+		keys := make([]any, len(matched))
+		for i, row := range matched {
+			keys[i] = value(row)
+		}
+		order := make([]int, len(matched))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			cmp := compare(keys[order[i]], keys[order[j]])
+			if p.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+		sorted := make([]T, len(matched))
+		for i, k := range order {
+			sorted[i] = matched[k]
+		}
+		matched = sorted
+	}
+
+	page.Pages = (len(matched) + p.Size - 1) / p.Size
+	if page.Pages == 0 {
+		page.Pages = 1
+	}
+	if page.Page > page.Pages {
+		page.Page = page.Pages
+	}
+
+	from := (page.Page - 1) * p.Size
+	to := min(from+p.Size, len(matched))
+	for _, row := range matched[from:to] {
+		cells := make([]Cell, len(cols))
+		for i, c := range cols {
+			cells[i] = Cell{Text: c.Text(row), Class: c.Class}
+		}
+		page.Rows = append(page.Rows, cells)
+	}
+
+	return page
+}
+
+// baseValues retourne les paramètres de la requête hors composant
+func baseValues(r *http.Request) url.Values {
+	base := url.Values{}
+	for k, v := range r.Form {
+		switch k {
+		case ParamPage, ParamSize, ParamSort, ParamDir, ParamQuery:
+		default:
+			base[k] = v
+		}
+	}
+	return base
+}
+
+// compare ordonne deux valeurs de tri ; les valeurs absentes passent en tête
+func compare(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch x := a.(type) {
+	case int:
+		y, _ := b.(int)
+		return cmpOrdered(x, y)
+	case float64:
+		y, _ := b.(float64)
+		return cmpOrdered(x, y)
+	case time.Time:
+		y, _ := b.(time.Time)
+		return x.Compare(y)
+	case string:
+		y, _ := b.(string)
+		return cmpOrdered(strings.ToLower(x), strings.ToLower(y))
+	}
+	return 0
+}
+
+func cmpOrdered[T int | float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// From est le rang de la première ligne affichée (1 pour la première)
+func (p Page) From() int {
+	if p.Matched == 0 {
+		return 0
+	}
+	return (p.Page-1)*p.Size + 1
+}
+
+// To est le rang de la dernière ligne affichée
+func (p Page) To() int {
+	return p.From() + len(p.Rows) - 1
+}
+
+// Sizes retourne les tailles de page proposées, par ordre croissant
+func (p Page) Sizes() []int {
+	sizes := append([]int(nil), PageSizes...)
+	sort.Ints(sizes)
+	return sizes
+}
+
+// URL construit le lien vers la même vue en remplaçant des paramètres du
+// composant : URL "page" 2, URL "sort" "site" "dir" "desc"...
+func (p Page) URL(pairs ...any) string {
+	v := url.Values{}
+	for k, vals := range p.base {
+		v[k] = vals
+	}
+	v.Set(ParamSize, strconv.Itoa(p.Size))
+	if p.Sort != "" {
+		v.Set(ParamSort, p.Sort)
+		if p.Desc {
+			v.Set(ParamDir, "desc")
+		}
+	}
+	if p.Query != "" {
+		v.Set(ParamQuery, p.Query)
+	}
+	v.Set(ParamPage, strconv.Itoa(p.Page))
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key, _ := pairs[i].(string)
+		switch val := pairs[i+1].(type) {
+		case string:
+			if val == "" {
+				v.Del(key)
+			} else {
+				v.Set(key, val)
+			}
+		case int:
+			v.Set(key, strconv.Itoa(val))
+		}
+	}
+	return p.path + "?" + v.Encode()
+}
+
+// SortURL retourne le lien de tri sur une colonne : croissant, puis
+// décroissant si la colonne est déjà triée en croissant
+func (p Page) SortURL(key string) string {
+	dir := ""
+	if p.Sort == key && !p.Desc {
+		dir = "desc"
+	}
+	return p.URL(ParamSort, key, ParamDir, dir, ParamPage, 1)
+}
+
+// SearchURL retourne le lien de recherche, sans le texte (ajouté par HTMX
+// depuis le champ de saisie) et revenu en première page
+func (p Page) SearchURL() string {
+	return p.URL(ParamQuery, "", ParamPage, 1)
+}
+
+// SizeURL retourne le lien pour changer de taille de page (ajoutée par HTMX
+// depuis la liste déroulante)
+func (p Page) SizeURL() string {
+	return p.URL(ParamSize, "", ParamPage, 1)
+}
+
+// Prev et Next indiquent si les pages voisines existent
+func (p Page) Prev() bool { return p.Page > 1 }
+func (p Page) Next() bool { return p.Page < p.Pages }
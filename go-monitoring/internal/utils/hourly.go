@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"sort"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// HourlyFromView répartit les sessions par site et heure de début, avec
+// l'heure de pic et l'heure médiane pondérée de chaque site, comme l'analyse
+// temporelle de tab2_comparaison.py. Les sessions sans date de début sont
+// ignorées.
+func HourlyFromView(ctx context.Context, sessions columnar.View) (models.HourlyAnalysis, error) {
+	bySite := make(map[string]*models.SiteHourly)
+
+	for it := sessions.Iter(); it.Next(); {
+		if it.Pos()%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return models.HourlyAnalysis{}, err
+			}
+		}
+
+		s := it.Row()
+		start := s.DatetimeStart()
+		if start.IsZero() {
+			continue
+		}
+
+		h := bySite[s.Site()]
+		if h == nil {
+			h = &models.SiteHourly{Site: s.Site()}
+			bySite[s.Site()] = h
+		}
+		hour := start.Hour()
+		if s.OK() {
+			h.OK[hour]++
+		} else {
+			h.NOK[hour]++
+		}
+		h.Counts[hour]++
+		h.Total++
+	}
+
+	var analysis models.HourlyAnalysis
+	for _, h := range bySite {
+		// Pic : la première heure en cas d'égalité, comme idxmax
+		for hour, n := range h.Counts {
+			if n > h.PeakCount {
+				h.PeakHour, h.PeakCount = hour, n
+			}
+		}
+		cumul := 0
+		for hour, n := range h.Counts {
+			cumul += n
+			if float64(cumul) >= float64(h.Total)/2 {
+				h.MedianHour = hour
+				break
+			}
+		}
+		if h.PeakCount > analysis.Max {
+			analysis.Max = h.PeakCount
+		}
+		analysis.Sites = append(analysis.Sites, *h)
+	}
+
+	sort.Slice(analysis.Sites, func(i, j int) bool {
+		return analysis.Sites[i].Site < analysis.Sites[j].Site
+	})
+	return analysis, nil
+}
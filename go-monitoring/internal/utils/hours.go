@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"sort"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// OperatingTimeFrom calcule les heures de fonctionnement par site et par
+// PDC depuis les tables de durées quotidiennes. La période filtrée est
+// réduite aux jours couverts par les tables, pour qu'un mode « toute
+// période » ne dilue pas les taux d'utilisation.
+func OperatingTimeFrom(sites []models.DurationsSiteDaily, pdcs []models.DurationsPDCDaily, filters models.Filters) models.OperatingTime {
+	var first, last time.Time
+	extend := func(day time.Time) {
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+	}
+	for _, d := range sites {
+		extend(d.Day)
+	}
+	for _, d := range pdcs {
+		extend(d.Day)
+	}
+
+	var ot models.OperatingTime
+	if first.IsZero() {
+		return ot
+	}
+	ot.Start = laterOf(filters.DateStart, first)
+	ot.End = earlierOf(filters.DateEnd, last.Add(24*time.Hour))
+	if !ot.End.After(ot.Start) {
+		return ot
+	}
+	ot.PeriodHours = ot.End.Sub(ot.Start).Hours()
+
+	inPeriod := func(site, source string, day time.Time) bool {
+		if len(filters.Sites) > 0 && !contains(filters.Sites, site) {
+			return false
+		}
+		if len(filters.Sources) > 0 && !contains(filters.Sources, source) {
+			return false
+		}
+		return !day.Before(ot.Start) && day.Before(ot.End)
+	}
+
+	for d := ot.Start.UTC().Truncate(24 * time.Hour); d.Before(ot.End); d = d.Add(24 * time.Hour) {
+		ot.Days = append(ot.Days, d)
+	}
+	dayIndex := func(day time.Time) int {
+		return int(day.UTC().Truncate(24*time.Hour).Sub(ot.Days[0]) / (24 * time.Hour))
+	}
+
+	// Nombre de PDC de chaque site, sur tout l'historique
+	type pdcKey struct{ site, pdc string }
+	pdcCount := make(map[string]int)
+	seen := make(map[pdcKey]bool)
+	for _, d := range pdcs {
+		k := pdcKey{d.Site, d.PDC}
+		if !seen[k] {
+			seen[k] = true
+			pdcCount[d.Site]++
+		}
+	}
+
+	siteMinutes := make(map[string]float64)
+	trends := make(map[string][]float64)
+	for _, d := range sites {
+		if !inPeriod(d.Site, d.Source, d.Day) {
+			continue
+		}
+		siteMinutes[d.Site] += d.DurMin
+		if trends[d.Site] == nil {
+			trends[d.Site] = make([]float64, len(ot.Days))
+		}
+		if i := dayIndex(d.Day); i >= 0 && i < len(ot.Days) {
+			trends[d.Site][i] += d.DurMin / 60
+		}
+	}
+
+	pdcMinutes := make(map[pdcKey]float64)
+	for _, d := range pdcs {
+		if inPeriod(d.Site, d.Source, d.Day) {
+			pdcMinutes[pdcKey{d.Site, d.PDC}] += d.DurMin
+		}
+	}
+
+	for site, minutes := range siteMinutes {
+		n := pdcCount[site]
+		if n == 0 {
+			n = 1
+		}
+		hours := minutes / 60
+		ot.TotalHours += hours
+		ot.Sites = append(ot.Sites, models.OperatingHours{
+			Site:        site,
+			PDCCount:    n,
+			Hours:       round(hours, 1),
+			Utilization: round(hours/(float64(n)*ot.PeriodHours)*100, 2),
+		})
+	}
+	ot.TotalHours = round(ot.TotalHours, 1)
+	sort.Slice(ot.Sites, func(i, j int) bool {
+		if ot.Sites[i].Hours != ot.Sites[j].Hours {
+			return ot.Sites[i].Hours > ot.Sites[j].Hours
+		}
+		return ot.Sites[i].Site < ot.Sites[j].Site
+	})
+
+	for k, minutes := range pdcMinutes {
+		hours := minutes / 60
+		ot.PDCs = append(ot.PDCs, models.OperatingHours{
+			Site:        k.site,
+			PDC:         k.pdc,
+			PDCCount:    1,
+			Hours:       round(hours, 1),
+			Utilization: round(hours/ot.PeriodHours*100, 2),
+		})
+	}
+	sort.Slice(ot.PDCs, func(i, j int) bool {
+		a, b := ot.PDCs[i], ot.PDCs[j]
+		if a.Site != b.Site {
+			return a.Site < b.Site
+		}
+		if a.Hours != b.Hours {
+			return a.Hours > b.Hours
+		}
+		return a.PDC < b.PDC
+	})
+
+	for _, s := range ot.Sites {
+		series := trends[s.Site]
+		for i := range series {
+			series[i] = round(series[i], 1)
+		}
+		ot.Trends = append(ot.Trends, models.HoursSeries{Name: s.Site, Hours: series})
+	}
+
+	return ot
+}
+
+func laterOf(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlierOf(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,347 @@
+package utils
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+// histogramBins est le nombre visé de classes des histogrammes d'énergie et
+// de puissance
+const histogramBins = 20
+
+// SessionStatsFromView calcule les statistiques d'énergie, de puissance, de
+// SOC et de durée, avec les mêmes périmètres que tab4_stats.py : l'énergie
+// totale sur tous les statuts, la moyenne et le max d'énergie sur les OK et
+// les NOK en fin de charge, le reste sur les OK en fin de charge (ou tous
+// les OK si aucun n'a de moment)
+func SessionStatsFromView(ctx context.Context, sessions columnar.View) (models.SessionStats, error) {
+	var stats models.SessionStats
+	var energy accumulator
+	var energies []float64
+	// Les deux périmètres OK sont cumulés en une passe ; okAll ne sert que
+	// si aucune session OK n'est en fin de charge
+	var okAll, okFin okStats
+	daily := make(map[time.Time]*models.DailyMax)
+
+	for it := sessions.Iter(); it.Next(); {
+		if it.Pos()%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return models.SessionStats{}, err
+			}
+		}
+
+		s := it.Row()
+		stats.Total++
+		if s.Charge900V() != 0 {
+			stats.Total900V++
+		}
+
+		fin := isFinDeCharge(s.Moment())
+		if s.OK() {
+			okAll.add(s)
+			if fin {
+				okFin.add(s)
+			}
+		}
+
+		e, ok := s.EnergyKwh()
+		if !ok {
+			continue
+		}
+		stats.EnergyTotal += e
+		if !s.OK() && !fin {
+			continue
+		}
+
+		energy.add(e, s)
+		energies = append(energies, e)
+		if start := s.DatetimeStart(); !start.IsZero() {
+			d := start.UTC().Truncate(24 * time.Hour)
+			if m := daily[d]; m == nil || e > m.Value {
+				daily[d] = &models.DailyMax{Day: d, Value: e, Site: s.Site(), PDC: s.PDC()}
+			}
+		}
+	}
+
+	stats.EnergyTotal = round(stats.EnergyTotal, 3)
+	stats.Energy = energy.result()
+	stats.EnergyHistogram = histogram(energies, 0)
+	if stats.Total > 0 {
+		stats.Pct900V = round(float64(stats.Total900V)/float64(stats.Total)*100, 2)
+	}
+
+	for _, m := range daily {
+		m.Value = round(m.Value, 3)
+		stats.DailyMaxEnergy = append(stats.DailyMaxEnergy, *m)
+	}
+	sort.Slice(stats.DailyMaxEnergy, func(i, j int) bool {
+		return stats.DailyMaxEnergy[i].Day.Before(stats.DailyMaxEnergy[j].Day)
+	})
+
+	if okFin.n > 0 {
+		okFin.result(&stats)
+	} else {
+		okAll.result(&stats)
+	}
+
+	return stats, nil
+}
+
+// okStats cumule les mesures d'un périmètre de sessions OK : puissances,
+// SOC et durée
+type okStats struct {
+	n                          int
+	meanPower, maxPower        accumulator
+	powers, socStarts, socEnds []float64
+	socGain, duration          mean
+}
+
+func (o *okStats) add(s columnar.Row) {
+	o.n++
+	if p, has := s.MeanPowerKw(); has {
+		o.meanPower.add(p, s)
+		o.powers = append(o.powers, p)
+	}
+	if p, has := s.MaxPowerKw(); has {
+		o.maxPower.add(p, s)
+	}
+
+	start, hasStart := s.SOCStart()
+	if hasStart {
+		o.socStarts = append(o.socStarts, start)
+	}
+	end, hasEnd := s.SOCEnd()
+	if hasEnd {
+		o.socEnds = append(o.socEnds, end)
+	}
+	if hasStart && hasEnd {
+		o.socGain.add(end - start)
+	}
+
+	if endTime, has := s.DatetimeEnd(); has && !s.DatetimeStart().IsZero() {
+		o.duration.add(endTime.Sub(s.DatetimeStart()).Minutes())
+	}
+}
+
+// result reporte les mesures du périmètre dans stats
+func (o *okStats) result(stats *models.SessionStats) {
+	stats.MeanPower = o.meanPower.result()
+	stats.MaxPower = o.maxPower.result()
+	stats.MeanPowerHistogram = histogram(o.powers, 0)
+	stats.SOCStartMean = round(average(o.socStarts), 2)
+	stats.SOCEndMean = round(average(o.socEnds), 2)
+	stats.SOCGainMean = round(o.socGain.value(), 2)
+	stats.SOCStartHistogram = histogram(o.socStarts, 5)
+	stats.SOCEndHistogram = histogram(o.socEnds, 5)
+	stats.DurationMean = round(o.duration.value(), 1)
+}
+
+func isFinDeCharge(moment string) bool {
+	return strings.EqualFold(strings.TrimSpace(moment), "Fin de charge")
+}
+
+// accumulator calcule la moyenne et le maximum d'une mesure, en retenant la
+// session du maximum
+type accumulator struct {
+	mean
+	stats models.ValueStats
+}
+
+func (a *accumulator) add(v float64, s columnar.Row) {
+	a.mean.add(v)
+	if a.n == 1 || v > a.stats.Max {
+		a.stats.Max = v
+		a.stats.MaxAt = sessionTime(s)
+		a.stats.MaxSite = s.Site()
+		a.stats.MaxPDC = s.PDC()
+	}
+}
+
+func (a *accumulator) result() models.ValueStats {
+	stats := a.stats
+	stats.Count = a.n
+	stats.Mean = round(a.value(), 3)
+	stats.Max = round(stats.Max, 3)
+	return stats
+}
+
+// sessionTime retourne la fin de la session, ou son début si elle n'est pas
+// terminée
+func sessionTime(s columnar.Row) time.Time {
+	if end, ok := s.DatetimeEnd(); ok {
+		return end
+	}
+	return s.DatetimeStart()
+}
+
+// mean est une moyenne incrémentale
+type mean struct {
+	n   int
+	sum float64
+}
+
+func (m *mean) add(v float64) {
+	m.n++
+	m.sum += v
+}
+
+func (m mean) value() float64 {
+	if m.n == 0 {
+		return 0
+	}
+	return m.sum / float64(m.n)
+}
+
+func average(values []float64) float64 {
+	var m mean
+	for _, v := range values {
+		m.add(v)
+	}
+	return m.value()
+}
+
+// histogram répartit des valeurs en classes de largeur width depuis 0 ; une
+// largeur nulle choisit une largeur ronde pour une vingtaine de classes. Les
+// valeurs négatives tombent dans la première classe, le maximum dans la
+// dernière.
+func histogram(values []float64, width float64) []models.HistogramBin {
+	if len(values) == 0 {
+		return nil
+	}
+
+	top := 0.0
+	for _, v := range values {
+		top = math.Max(top, v)
+	}
+	if width <= 0 {
+		width = niceWidth(top / histogramBins)
+	}
+
+	n := int(math.Ceil(top / width))
+	if n == 0 {
+		n = 1
+	}
+	bins := make([]models.HistogramBin, n)
+	for i := range bins {
+		bins[i].From = round(float64(i)*width, 3)
+		bins[i].To = round(float64(i+1)*width, 3)
+	}
+	for _, v := range values {
+		i := int(v / width)
+		if i < 0 {
+			i = 0
+		}
+		if i >= n {
+			i = n - 1
+		}
+		bins[i].Count++
+	}
+	return bins
+}
+
+// niceWidth arrondit une largeur de classe à 1, 2 ou 5 × 10^k
+func niceWidth(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch f := raw / exp; {
+	case f <= 1:
+		return exp
+	case f <= 2:
+		return 2 * exp
+	case f <= 5:
+		return 5 * exp
+	default:
+		return 10 * exp
+	}
+}
+
+// UnknownVehicle regroupe les charges sans véhicule identifié
+const UnknownVehicle = "Unknown"
+
+// missingVehicles sont les valeurs manquantes écrites par MySQL ou pandas,
+// comparées sans tenir compte de la casse
+var missingVehicles = []string{"nan", "none", "null", UnknownVehicle}
+
+// VehicleName normalise le véhicule d'une charge : vide, "nan", "none" ou
+// "null", quelle que soit la casse, deviennent UnknownVehicle
+func VehicleName(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return UnknownVehicle
+	}
+	for _, missing := range missingVehicles {
+		if strings.EqualFold(v, missing) {
+			return UnknownVehicle
+		}
+	}
+	return v
+}
+
+// VehicleStatsFromCharges calcule le taux de réussite par type de véhicule,
+// hors véhicules inconnus, triés par nombre de charges puis par taux de
+// réussite décroissants
+func VehicleStatsFromCharges(charges []models.ChargeMAC) []models.VehicleStats {
+	byVehicle := make(map[string]*models.VehicleStats)
+	for _, c := range charges {
+		name := VehicleName(c.Vehicle)
+		if name == UnknownVehicle {
+			continue
+		}
+
+		v := byVehicle[name]
+		if v == nil {
+			v = &models.VehicleStats{Vehicle: name}
+			byVehicle[name] = v
+		}
+		v.Total++
+		if c.IsOK {
+			v.OK++
+		} else {
+			v.NOK++
+		}
+	}
+
+	result := make([]models.VehicleStats, 0, len(byVehicle))
+	for _, v := range byVehicle {
+		v.TauxReussite = round(float64(v.OK)/float64(v.Total)*100, 2)
+		v.TauxEchec = round(100-v.TauxReussite, 2)
+		result = append(result, *v)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		if a.TauxReussite != b.TauxReussite {
+			return a.TauxReussite > b.TauxReussite
+		}
+		return a.Vehicle < b.Vehicle
+	})
+	return result
+}
+
+// RankVehicles retourne les véhicules d'au moins minTotal charges, par taux
+// de réussite décroissant : un véhicule rare ne peut pas tenir la tête du
+// classement sur deux ou trois charges
+func RankVehicles(stats []models.VehicleStats, minTotal int) []models.VehicleStats {
+	var ranked []models.VehicleStats
+	for _, v := range stats {
+		if v.Total >= minTotal {
+			ranked = append(ranked, v)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].TauxReussite > ranked[j].TauxReussite
+	})
+	return ranked
+}
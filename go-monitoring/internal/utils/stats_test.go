@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+)
+
+func floatp(v float64) *float64 { return &v }
+
+func TestSessionStatsPerimeters(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	okFin := models.Session{ID: "fin", DatetimeStart: start, DatetimeEnd: &end, Site: "A", PDC: "A1", Moment: "Fin de charge", EnergyKwh: floatp(20), MeanPowerKw: floatp(40), SOCStart: floatp(20), SOCEnd: floatp(80)}
+	okOther := models.Session{ID: "init", DatetimeStart: start, Site: "B", PDC: "B1", Moment: "Init", EnergyKwh: floatp(5), MeanPowerKw: floatp(10)}
+	nok := models.Session{ID: "nok", DatetimeStart: start, Site: "C", PDC: "C1", StateOfCharge: 1, Moment: "Charge", EnergyKwh: floatp(7), MeanPowerKw: floatp(100)}
+
+	tests := []struct {
+		name        string
+		rows        []models.Session
+		wantPowers  int
+		wantPower   float64
+		wantEnergy  float64
+		wantTotal   float64
+		wantSOCGain float64
+		wantMinutes float64
+	}{
+		{
+			name:       "OK en fin de charge",
+			rows:       []models.Session{okFin, okOther, nok},
+			wantPowers: 1, wantPower: 40,
+			wantEnergy: 12.5, wantTotal: 32,
+			wantSOCGain: 60, wantMinutes: 30,
+		},
+		{
+			// Aucune session OK en fin de charge : repli sur tous les OK
+			name:       "repli sur tous les OK",
+			rows:       []models.Session{okOther, nok},
+			wantPowers: 1, wantPower: 10,
+			wantEnergy: 5, wantTotal: 12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := SessionStatsFromView(context.Background(), columnar.FromRows(tt.rows).All())
+			if err != nil {
+				t.Fatalf("SessionStatsFromView: %v", err)
+			}
+			if stats.MeanPower.Count != tt.wantPowers || stats.MeanPower.Mean != tt.wantPower {
+				t.Errorf("mean power = %d sessions, %v, want %d, %v", stats.MeanPower.Count, stats.MeanPower.Mean, tt.wantPowers, tt.wantPower)
+			}
+			if stats.Energy.Mean != tt.wantEnergy || stats.EnergyTotal != tt.wantTotal {
+				t.Errorf("energy mean = %v, total = %v, want %v and %v", stats.Energy.Mean, stats.EnergyTotal, tt.wantEnergy, tt.wantTotal)
+			}
+			if stats.SOCGainMean != tt.wantSOCGain || stats.DurationMean != tt.wantMinutes {
+				t.Errorf("SOC gain = %v, duration = %v, want %v and %v", stats.SOCGainMean, stats.DurationMean, tt.wantSOCGain, tt.wantMinutes)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/monitoring/charging-stations/internal/columnar"
 	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/rollup"
 )
 
 // Constantes pour les moments et palettes
@@ -34,71 +39,101 @@ var (
 	BaseChargeURL = "https://elto.nidec-asi-online.com/Charge/detail?id="
 )
 
+// ctxCheckEvery fixe la fréquence de vérification d'annulation dans les boucles
+const ctxCheckEvery = 4096
+
 // FilterSessions filtre les sessions selon les critères
-func FilterSessions(sessions []models.Session, filters models.Filters) []models.Session {
-	var filtered []models.Session
+func FilterSessions(sessions columnar.View, filters models.Filters) columnar.View {
+	filtered, _ := FilterSessionsContext(context.Background(), sessions, filters)
+	return filtered
+}
 
-	for _, s := range sessions {
-		// Filtre site
-		if len(filters.Sites) > 0 && !contains(filters.Sites, s.Site) {
-			continue
-		}
+// FilterSessionsContext filtre les sessions et s'interrompt si ctx est annulé
+// (requête HTMX abandonnée par le navigateur)
+func FilterSessionsContext(ctx context.Context, sessions columnar.View, filters models.Filters) (columnar.View, error) {
+	var idx []int32
 
-		// Filtre date
-		if !s.DatetimeStart.IsZero() {
-			if s.DatetimeStart.Before(filters.DateStart) || s.DatetimeStart.After(filters.DateEnd) {
-				continue
+	for it := sessions.Iter(); it.Next(); {
+		if it.Pos()%ctxCheckEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return columnar.View{}, err
 			}
 		}
 
-		// Filtre type erreur
-		// Si un filtre de type d'erreur est appliqué, on filtre uniquement les erreurs
-		// Les sessions OK sont toujours conservées (comme dans le code Python)
-		if len(filters.TypesErreur) > 0 {
-			// Si la session est en erreur ET ne correspond pas aux types sélectionnés, on l'exclut
-			// Les sessions OK (StateOfCharge == 0) passent toujours ce filtre
-			if s.StateOfCharge != 0 && !contains(filters.TypesErreur, s.TypeErreur) {
-				continue
-			}
+		if s := it.Row(); MatchSession(s, filters) {
+			idx = append(idx, int32(s.Index()))
 		}
+	}
 
-		// Filtre moment
-		// Si un filtre de moment est appliqué, on filtre uniquement les erreurs
-		// Les sessions OK sont toujours conservées (comme dans le code Python)
-		if len(filters.Moments) > 0 {
-			// Si la session est en erreur ET ne correspond pas aux moments sélectionnés, on l'exclut
-			// Les sessions OK (StateOfCharge == 0) passent toujours ce filtre
-			if s.StateOfCharge != 0 && !contains(filters.Moments, s.Moment) {
-				continue
-			}
+	return sessions.Columns().Select(idx), nil
+}
+
+// MatchSession indique si une session passe les filtres
+func MatchSession(s columnar.Row, filters models.Filters) bool {
+	// Filtre site
+	if len(filters.Sites) > 0 && !contains(filters.Sites, s.Site()) {
+		return false
+	}
+
+	// Filtre base d'origine
+	if len(filters.Sources) > 0 && !contains(filters.Sources, s.Source()) {
+		return false
+	}
+
+	// Filtre date
+	if start := s.DatetimeStart(); !start.IsZero() {
+		if start.Before(filters.DateStart) || start.After(filters.DateEnd) {
+			return false
 		}
+	}
 
-		filtered = append(filtered, s)
+	// Filtre type erreur
+	// Si un filtre de type d'erreur est appliqué, on filtre uniquement les erreurs
+	// Les sessions OK sont toujours conservées (comme dans le code Python)
+	if len(filters.TypesErreur) > 0 {
+		// Si la session est en erreur ET ne correspond pas aux types sélectionnés, on l'exclut
+		// Les sessions OK (StateOfCharge == 0) passent toujours ce filtre
+		if !s.OK() && !contains(filters.TypesErreur, s.TypeErreur()) {
+			return false
+		}
 	}
 
-	return filtered
+	// Filtre moment
+	// Si un filtre de moment est appliqué, on filtre uniquement les erreurs
+	// Les sessions OK sont toujours conservées (comme dans le code Python)
+	if len(filters.Moments) > 0 {
+		// Si la session est en erreur ET ne correspond pas aux moments sélectionnés, on l'exclut
+		// Les sessions OK (StateOfCharge == 0) passent toujours ce filtre
+		if !s.OK() && !contains(filters.Moments, s.Moment()) {
+			return false
+		}
+	}
+
+	return true
 }
 
 // CalculateKPIs calcule les KPIs depuis les sessions filtrées
-func CalculateKPIs(sessions []models.Session, filters models.Filters) models.KPISummary {
-	total := len(sessions)
+func CalculateKPIs(sessions columnar.View, filters models.Filters) models.KPISummary {
+	return KPIsFromCells(rollup.Aggregate(sessions))
+}
+
+// KPIsFromCells calcule les KPIs depuis des cellules du cube
+func KPIsFromCells(cells []rollup.Cell) models.KPISummary {
 	ok := 0
 	nok := 0
 
 	sitesMap := make(map[string]bool)
 	pdcMap := make(map[string]bool)
 
-	for _, s := range sessions {
-		sitesMap[s.Site] = true
-		pdcMap[s.PDC] = true
+	for _, c := range cells {
+		sitesMap[c.Site] = true
+		pdcMap[c.PDC] = true
 
-		if s.StateOfCharge == 0 {
-			ok++
-		} else {
-			nok++
-		}
+		ok += c.OK
+		nok += c.NOK
 	}
 
+	total := ok + nok
 	tauxReussite := 0.0
 	tauxEchec := 0.0
 	if total > 0 {
@@ -118,22 +153,29 @@ func CalculateKPIs(sessions []models.Session, filters models.Filters) models.KPI
 }
 
 // GetStatsBySite calcule les stats par site
-func GetStatsBySite(sessions []models.Session) []models.SiteStats {
+func GetStatsBySite(sessions columnar.View) []models.SiteStats {
+	return StatsBySiteFromCells(rollup.Aggregate(sessions))
+}
+
+// StatsBySiteFromCells calcule les stats par site depuis des cellules du cube
+func StatsBySiteFromCells(cells []rollup.Cell) []models.SiteStats {
 	siteMap := make(map[string]*models.SiteStats)
+	siteSources := make(map[string]map[string]bool)
 
-	for _, s := range sessions {
-		if _, exists := siteMap[s.Site]; !exists {
-			siteMap[s.Site] = &models.SiteStats{
-				Site: s.Site,
+	for _, c := range cells {
+		if _, exists := siteMap[c.Site]; !exists {
+			siteMap[c.Site] = &models.SiteStats{
+				Site: c.Site,
 			}
+			siteSources[c.Site] = make(map[string]bool)
 		}
 
-		stats := siteMap[s.Site]
-		stats.Total++
-		if s.StateOfCharge == 0 {
-			stats.OK++
-		} else {
-			stats.NOK++
+		stats := siteMap[c.Site]
+		stats.Total += c.Total()
+		stats.OK += c.OK
+		stats.NOK += c.NOK
+		if c.Source != "" {
+			siteSources[c.Site][c.Source] = true
 		}
 	}
 
@@ -143,6 +185,7 @@ func GetStatsBySite(sessions []models.Session) []models.SiteStats {
 			stats.TauxReussite = round(float64(stats.OK)/float64(stats.Total)*100, 2)
 			stats.TauxEchec = round(float64(stats.NOK)/float64(stats.Total)*100, 2)
 		}
+		stats.Source = joinSet(siteSources[stats.Site])
 		result = append(result, *stats)
 	}
 
@@ -150,27 +193,30 @@ func GetStatsBySite(sessions []models.Session) []models.SiteStats {
 }
 
 // GetStatsByPDC calcule les stats par PDC pour un site
-func GetStatsByPDC(sessions []models.Session, site string) []models.PDCStats {
+func GetStatsByPDC(sessions columnar.View, site string) []models.PDCStats {
+	return StatsByPDCFromCells(rollup.Aggregate(sessions), site)
+}
+
+// StatsByPDCFromCells calcule les stats par PDC d'un site depuis des
+// cellules du cube
+func StatsByPDCFromCells(cells []rollup.Cell, site string) []models.PDCStats {
 	pdcMap := make(map[string]*models.PDCStats)
 
-	for _, s := range sessions {
-		if s.Site != site {
+	for _, c := range cells {
+		if c.Site != site {
 			continue
 		}
 
-		if _, exists := pdcMap[s.PDC]; !exists {
-			pdcMap[s.PDC] = &models.PDCStats{
-				PDC: s.PDC,
+		if _, exists := pdcMap[c.PDC]; !exists {
+			pdcMap[c.PDC] = &models.PDCStats{
+				PDC: c.PDC,
 			}
 		}
 
-		stats := pdcMap[s.PDC]
-		stats.Total++
-		if s.StateOfCharge == 0 {
-			stats.OK++
-		} else {
-			stats.NOK++
-		}
+		stats := pdcMap[c.PDC]
+		stats.Total += c.Total()
+		stats.OK += c.OK
+		stats.NOK += c.NOK
 	}
 
 	var result []models.PDCStats
@@ -185,12 +231,18 @@ func GetStatsByPDC(sessions []models.Session, site string) []models.PDCStats {
 }
 
 // GetMomentCounts compte les erreurs par moment
-func GetMomentCounts(sessions []models.Session) []models.MomentCount {
+func GetMomentCounts(sessions columnar.View) []models.MomentCount {
+	return MomentCountsFromCells(rollup.Aggregate(sessions))
+}
+
+// MomentCountsFromCells compte les erreurs par moment depuis des cellules
+// du cube
+func MomentCountsFromCells(cells []rollup.Cell) []models.MomentCount {
 	counts := make(map[string]int)
 
-	for _, s := range sessions {
-		if s.StateOfCharge != 0 { // Erreur
-			counts[s.Moment]++
+	for _, c := range cells {
+		if c.NOK > 0 { // Erreur
+			counts[c.Moment] += c.NOK
 		}
 	}
 
@@ -208,27 +260,23 @@ func GetMomentCounts(sessions []models.Session) []models.MomentCount {
 }
 
 // GetCodeOccurrences calcule les occurrences par code d'erreur
-func GetCodeOccurrences(sessions []models.Session, isEVI bool) map[int]*models.CodeOccurrence {
+func GetCodeOccurrences(sessions columnar.View, isEVI bool) map[int]*models.CodeOccurrence {
+	return CodeOccurrencesFromCells(rollup.Aggregate(sessions), isEVI)
+}
+
+// CodeOccurrencesFromCells calcule les occurrences par code d'erreur depuis
+// des cellules du cube
+func CodeOccurrencesFromCells(cells []rollup.Cell, isEVI bool) map[int]*models.CodeOccurrence {
 	occurrences := make(map[int]*models.CodeOccurrence)
 
-	for _, s := range sessions {
-		if s.StateOfCharge == 0 { // Pas une erreur
+	for _, c := range cells {
+		if c.NOK == 0 { // Pas une erreur
 			continue
 		}
 
-		var code int
-		if isEVI {
-			if s.EVIErrorCode != nil && *s.EVIErrorCode != 0 {
-				code = *s.EVIErrorCode
-			} else {
-				continue
-			}
-		} else {
-			if s.DownstreamCodePC != nil && *s.DownstreamCodePC != 0 && *s.DownstreamCodePC != 8192 {
-				code = *s.DownstreamCodePC
-			} else {
-				continue
-			}
+		code, ok := errorCode(c, isEVI)
+		if !ok {
+			continue
 		}
 
 		if _, exists := occurrences[code]; !exists {
@@ -239,8 +287,8 @@ func GetCodeOccurrences(sessions []models.Session, isEVI bool) map[int]*models.C
 		}
 
 		occ := occurrences[code]
-		occ.Total++
-		occ.ByMoment[s.Moment]++
+		occ.Total += c.NOK
+		occ.ByMoment[c.Moment] += c.NOK
 	}
 
 	// Calculer les pourcentages
@@ -258,6 +306,120 @@ func GetCodeOccurrences(sessions []models.Session, isEVI bool) map[int]*models.C
 	return occurrences
 }
 
+// errorCode retourne le code EVI ou DownStream d'une cellule ; le code
+// DownStream 8192 n'est pas une erreur
+func errorCode(c rollup.Cell, isEVI bool) (int, bool) {
+	if isEVI {
+		return c.EVICode, c.EVICode != 0
+	}
+	return c.DSCode, c.DSCode != 0 && c.DSCode != 8192
+}
+
+// pivotCode répartit une cellule entre les pivots EVI et DownStream selon
+// les règles de tab5_projection.py : EVI si le code DownStream vaut 8192,
+// ou 0 avec un code EVI ; DownStream pour tout autre code DownStream
+func pivotCode(c rollup.Cell, isEVI bool) (int, bool) {
+	evi := c.DSCode == 8192 || (c.DSCode == 0 && c.EVICode != 0)
+	if isEVI {
+		return c.EVICode, evi
+	}
+	return c.DSCode, c.DSCode != 0 && c.DSCode != 8192
+}
+
+// CodePivotFromCells croise les erreurs par site (ou site × PDC si byPDC)
+// avec les couples moment × code, comme le pivot de tab5_projection.py : le
+// moment est celui de l'étape EVI (MapMoment), pas la colonne moment
+func CodePivotFromCells(cells []rollup.Cell, isEVI, byPDC bool) models.CodePivot {
+	type rowKey struct{ site, pdc string }
+	counts := make(map[rowKey]map[models.PivotColumn]int)
+	codes := make(map[string]map[int]bool)
+
+	for _, c := range cells {
+		if c.NOK == 0 {
+			continue
+		}
+		code, ok := pivotCode(c, isEVI)
+		if !ok {
+			continue
+		}
+		moment := MapMoment(c.EVIStep)
+
+		k := rowKey{site: c.Site}
+		if byPDC {
+			k.pdc = c.PDC
+		}
+		if counts[k] == nil {
+			counts[k] = make(map[models.PivotColumn]int)
+		}
+		if codes[moment] == nil {
+			codes[moment] = make(map[int]bool)
+		}
+		counts[k][models.PivotColumn{Moment: moment, Code: code}] += c.NOK
+		codes[moment][code] = true
+	}
+
+	var pivot models.CodePivot
+	for _, moment := range pivotMoments(codes) {
+		sorted := make([]int, 0, len(codes[moment]))
+		for code := range codes[moment] {
+			sorted = append(sorted, code)
+		}
+		sort.Ints(sorted)
+
+		pivot.Moments = append(pivot.Moments, models.PivotMoment{Moment: moment, Span: len(sorted)})
+		for _, code := range sorted {
+			pivot.Columns = append(pivot.Columns, models.PivotColumn{Moment: moment, Code: code})
+		}
+	}
+
+	pivot.Totals = make([]int, len(pivot.Columns))
+	for k, byColumn := range counts {
+		row := models.PivotRow{Site: k.site, PDC: k.pdc, Counts: make([]int, len(pivot.Columns))}
+		for i, col := range pivot.Columns {
+			n := byColumn[col]
+			row.Counts[i] = n
+			row.Total += n
+			pivot.Totals[i] += n
+		}
+		pivot.Total += row.Total
+		pivot.Rows = append(pivot.Rows, row)
+	}
+
+	for i := range pivot.Rows {
+		pivot.Rows[i].Percentage = round(float64(pivot.Rows[i].Total)/float64(pivot.Total)*100, 1)
+	}
+
+	sort.Slice(pivot.Rows, func(i, j int) bool {
+		if pivot.Rows[i].Site != pivot.Rows[j].Site {
+			return pivot.Rows[i].Site < pivot.Rows[j].Site
+		}
+		return pivot.Rows[i].PDC < pivot.Rows[j].PDC
+	})
+
+	return pivot
+}
+
+// pivotMoments ordonne les moments présents selon MomentOrder, les moments
+// inconnus de MomentOrder à la suite par ordre alphabétique
+func pivotMoments(present map[string]map[int]bool) []string {
+	var moments []string
+	for _, m := range MomentOrder {
+		if _, ok := present[m]; ok {
+			moments = append(moments, m)
+		}
+	}
+
+	var others []string
+	for m := range present {
+		if !contains(MomentOrder, m) {
+			others = append(others, m)
+		}
+	}
+	sort.Strings(others)
+
+	return append(moments, others...)
+}
+
 // MapMoment mappe un step EVI vers un moment
 func MapMoment(step int) string {
 	switch {
@@ -321,11 +483,11 @@ func GetChargeLink(id string) string {
 }
 
 // GetUniqueSites retourne la liste unique des sites
-func GetUniqueSites(sessions []models.Session) []string {
+func GetUniqueSites(sessions columnar.View) []string {
 	sitesMap := make(map[string]bool)
-	for _, s := range sessions {
-		if s.Site != "" {
-			sitesMap[s.Site] = true
+	for it := sessions.Iter(); it.Next(); {
+		if site := it.Row().Site(); site != "" {
+			sitesMap[site] = true
 		}
 	}
 
@@ -337,12 +499,45 @@ func GetUniqueSites(sessions []models.Session) []string {
 	return sites
 }
 
+// GetSiteSources associe chaque site à sa base d'origine (sites d'une même
+// base regroupés, plusieurs bases séparées par des virgules)
+func GetSiteSources(sessions columnar.View) map[string]string {
+	seen := make(map[string]map[string]bool)
+	for it := sessions.Iter(); it.Next(); {
+		site, source := it.Row().Site(), it.Row().Source()
+		if site == "" || source == "" {
+			continue
+		}
+		if seen[site] == nil {
+			seen[site] = make(map[string]bool)
+		}
+		seen[site][source] = true
+	}
+
+	result := make(map[string]string, len(seen))
+	for site, sources := range seen {
+		result[site] = joinSet(sources)
+	}
+
+	return result
+}
+
+// joinSet joint les clés d'un ensemble triées, séparées par des virgules
+func joinSet(set map[string]bool) string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
 // GetUniquePDCs retourne la liste unique des PDCs pour un site
-func GetUniquePDCs(sessions []models.Session, site string) []string {
+func GetUniquePDCs(sessions columnar.View, site string) []string {
 	pdcMap := make(map[string]bool)
-	for _, s := range sessions {
-		if s.Site == site && s.PDC != "" {
-			pdcMap[s.PDC] = true
+	for it := sessions.Iter(); it.Next(); {
+		if s := it.Row(); s.Site() == site && s.PDC() != "" {
+			pdcMap[s.PDC()] = true
 		}
 	}
 
@@ -405,12 +600,17 @@ func round(val float64, precision int) float64 {
 	for i := 0; i < precision; i++ {
 		ratio *= 10
 	}
-	return float64(int(val*ratio+0.5)) / ratio
+	return math.Round(val*ratio) / ratio
 }
 
 // GetTop10Sites retourne les top 10 sites avec le plus de charges
-func GetTop10Sites(sessions []models.Session) []models.SiteStats {
-	stats := GetStatsBySite(sessions)
+func GetTop10Sites(sessions columnar.View) []models.SiteStats {
+	return Top10SitesFromCells(rollup.Aggregate(sessions))
+}
+
+// Top10SitesFromCells retourne les top 10 sites depuis des cellules du cube
+func Top10SitesFromCells(cells []rollup.Cell) []models.SiteStats {
+	stats := StatsBySiteFromCells(cells)
 
 	// Tri par total décroissant
 	for i := 0; i < len(stats); i++ {
@@ -437,6 +637,9 @@ func GetActiveDefauts(defauts []models.Defaut, filters models.Filters) []models.
 		if len(filters.Sites) > 0 && !contains(filters.Sites, d.Site) {
 			continue
 		}
+		if len(filters.Sources) > 0 && !contains(filters.Sources, d.Source) {
+			continue
+		}
 
 		// Seulement les défauts actifs (date_fin IS NULL)
 		if d.DateFin == nil {
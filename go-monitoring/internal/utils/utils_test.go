@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/monitoring/charging-stations/internal/columnar"
+	"github.com/monitoring/charging-stations/internal/models"
+	"github.com/monitoring/charging-stations/internal/rollup"
+)
+
+func intp(v int) *int { return &v }
+
+func TestCodePivotFromCells(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+	cells := rollup.Aggregate(columnar.FromRows([]models.Session{
+		// Codes EVI et DownStream : seulement dans le pivot DownStream
+		{ID: "1", DatetimeStart: start, Site: "A", PDC: "A1", StateOfCharge: 1, Moment: "Init", EVIErrorCode: intp(12), DownstreamCodePC: intp(4), EVIMomentStep: intp(8)},
+		// DownStream 8192 sans code EVI : pivot EVI, code 0
+		{ID: "2", DatetimeStart: start, Site: "A", PDC: "A2", StateOfCharge: 1, Moment: "Charge", DownstreamCodePC: intp(8192), EVIMomentStep: intp(1)},
+		// Code EVI seul : le moment vient de l'étape EVI, pas de la colonne
+		{ID: "3", DatetimeStart: start, Site: "B", PDC: "B1", StateOfCharge: 1, Moment: "Charge", EVIErrorCode: intp(7), EVIMomentStep: intp(5)},
+		{ID: "4", DatetimeStart: start, Site: "B", PDC: "B1", StateOfCharge: 1, Moment: "Charge", EVIErrorCode: intp(7), DownstreamCodePC: intp(0)},
+		// Session OK et erreur sans code : hors pivots
+		{ID: "5", DatetimeStart: start, Site: "B", PDC: "B1", EVIErrorCode: intp(3)},
+		{ID: "6", DatetimeStart: start, Site: "B", PDC: "B1", StateOfCharge: 1, Moment: "Init"},
+	}).All())
+
+	tests := []struct {
+		name  string
+		isEVI bool
+		want  models.CodePivot
+	}{
+		{
+			name:  "EVI",
+			isEVI: true,
+			want: models.CodePivot{
+				Moments: []models.PivotMoment{{Moment: "Init", Span: 1}, {Moment: "Lock Connector", Span: 1}, {Moment: "Fin de charge", Span: 1}},
+				Columns: []models.PivotColumn{{Moment: "Init", Code: 0}, {Moment: "Lock Connector", Code: 7}, {Moment: "Fin de charge", Code: 7}},
+				Rows: []models.PivotRow{
+					{Site: "A", Counts: []int{1, 0, 0}, Total: 1, Percentage: 33.3},
+					{Site: "B", Counts: []int{0, 1, 1}, Total: 2, Percentage: 66.7},
+				},
+				Totals: []int{1, 1, 1},
+				Total:  3,
+			},
+		},
+		{
+			name:  "DownStream",
+			isEVI: false,
+			want: models.CodePivot{
+				Moments: []models.PivotMoment{{Moment: "Charge", Span: 1}},
+				Columns: []models.PivotColumn{{Moment: "Charge", Code: 4}},
+				Rows:    []models.PivotRow{{Site: "A", Counts: []int{1}, Total: 1, Percentage: 100}},
+				Totals:  []int{1},
+				Total:   1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodePivotFromCells(cells, tt.isEVI, false); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CodePivotFromCells = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}